@@ -1,18 +1,50 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/app/client"
+	"gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
-func parseArgs() (remote string) {
+func parseArgs() (remote string, tlsConfig *tls.Config) {
 	dFlag := flag.Bool("d", false, "enable debug log level")
 	aFlag := flag.String("a", "127.0.0.1", "server address (default: 127.0.0.1)")
 	pFlag := flag.String("p", "3333", "server port (default: 3333)")
+	retryFlag := flag.Int("retry", 1, "nouvelles tentatives d'un GET dont l'empreinte ne correspond pas")
+	tlsFlag := flag.Bool("tls", false, "se connecter en TLS")
+	insecureFlag := flag.Bool("insecure", false, "TLS sans vérification du certificat serveur (implique -tls)")
+	caFlag := flag.String("ca", "", "autorité de confiance pour le certificat serveur (TLS)")
+	certFlag := flag.String("cert", "", "certificat client PEM (mTLS du port de contrôle)")
+	keyFlag := flag.String("key", "", "clé privée PEM du certificat client")
+	uFlag := flag.String("u", "", "nom d'utilisateur, si le serveur exige une authentification")
+	oFlag := flag.String("o", client.OutputText, "format d'affichage de LIST et TREE : text ou json (un objet par ligne ; pour TREE, name est le chemin relatif et depth la profondeur)")
+	keepaliveFlag := flag.Int("keepalive", 60, "secondes entre deux NOOP pendant la saisie d'une commande, si le serveur n'annonce pas son délai d'inactivité (0 : jamais)")
+	timeoutFlag := flag.Int("timeout", 20, "secondes d'attente d'une réponse ou d'un bloc de données du serveur")
+	featuresFlag := flag.String("features", strings.Join(proto.SupportedFeatures, ","), "fonctionnalités du protocole annoncées au serveur, séparées par des virgules")
 	flag.Parse()
 
+	client.GetRetries = *retryFlag
+	client.Username = *uFlag
+	client.Features = strings.Split(*featuresFlag, ",")
+	if *oFlag != client.OutputText && *oFlag != client.OutputJSON {
+		slog.Error("format de sortie inconnu : " + *oFlag)
+		os.Exit(1)
+	}
+	client.OutputFormat = *oFlag
+	client.KeepaliveInterval = time.Duration(*keepaliveFlag) * time.Second
+	if *timeoutFlag <= 0 {
+		slog.Error("délai d'attente invalide : " + strconv.Itoa(*timeoutFlag))
+		os.Exit(1)
+	}
+	proto.MessageTimeout = time.Duration(*timeoutFlag) * time.Second
+
 	if *dFlag {
 		slog.SetLogLoggerLevel(slog.LevelDebug)
 		slog.Debug("Set logging level to debug")
@@ -24,11 +56,20 @@ func parseArgs() (remote string) {
 		port = "3334"
 	}
 
+	if *tlsFlag || *insecureFlag {
+		var err error
+		tlsConfig, err = client.NewTLSConfig(*caFlag, *certFlag, *keyFlag, *insecureFlag)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
 	remote = *aFlag + ":" + port
 	return
 }
 
 func main() {
-	remote := parseArgs()
-	client.Run(remote)
+	remote, tlsConfig := parseArgs()
+	client.Run(remote, tlsConfig)
 }
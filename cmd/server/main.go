@@ -1,17 +1,43 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
+	"fmt"
 	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/app/server"
 )
 
-func parseArgs() (port *string, controlPort *string) {
+func parseArgs() server.Config {
 
 	logLevel := flag.Bool("d", false, "enable debug log level")
-	port = flag.String("p", "3333", "server port (default: 3333)")
-	controlPort = flag.String("cp", "3334", "Port de contrôle")
+	port := flag.String("p", "3333", "server port (default: 3333)")
+	controlPort := flag.String("cp", "3334", "Port de contrôle")
+	controlTakeover := flag.String("control-takeover", server.ReprendreRefuser, "Connexion au port de contrôle déjà occupé : refuse ou replace")
+	root := flag.String("root", "Docs", "Dossier servi aux clients")
+	treeMax := flag.Int("tree-max", 10000, "Nombre maximal d'éléments renvoyés par TREE")
+	drainTimeout := flag.Int("drain-timeout", 30, "Secondes laissées aux commandes en cours lors de l'arrêt (0 : pas de limite)")
+	idleTimeout := flag.Int("idle-timeout", 300, "Secondes d'inactivité entre deux commandes avant de fermer la session (0 : pas de limite)")
+	transferTimeout := flag.Int("transfer-timeout", 20, "Secondes sans progression d'une lecture ou écriture pendant un échange ou un transfert")
+	maxSessionDuration := flag.Int("max-session-duration", 0, "Durée maximale d'une session en secondes (0 : pas de limite)")
+	maxSessions := flag.Int("max-sessions", 100, "Nombre maximal de sessions sur le port normal (0 : pas de limite)")
+	maxPerIP := flag.Int("max-per-ip", 10, "Nombre maximal de sessions depuis une même adresse IP (0 : pas de limite)")
+	maxPerUser := flag.Int("max-per-user", 5, "Nombre maximal de sessions d'un même utilisateur (0 : pas de limite)")
+	certFile := flag.String("cert", "", "Certificat PEM du serveur (active TLS sur les deux ports)")
+	keyFile := flag.String("key", "", "Clé privée PEM du serveur")
+	caFile := flag.String("ca", "", "Autorité de confiance pour les certificats clients")
+	controlMTLS := flag.Bool("control-mtls", false, "Exiger un certificat client signé par -ca sur le port de contrôle")
+	usersFile := flag.String("users", "", "Fichier JSON des utilisateurs (active l'authentification USER/PASS)")
+	auditFile := flag.String("audit", "", "Journal d'audit des refus de permission (stderr par défaut)")
+	hashPassword := flag.Bool("hash", false, "Lit un mot de passe sur l'entrée standard, affiche son empreinte bcrypt et quitte")
+	configFile := flag.String("config", "", "Fichier de configuration JSON (les options de la ligne de commande sont prioritaires)")
 
 	flag.Parse()
 
@@ -20,10 +46,116 @@ func parseArgs() (port *string, controlPort *string) {
 		slog.Debug("Set logging level to debug")
 	}
 
-	return
+	if *hashPassword {
+		afficherEmpreinte()
+	}
+
+	cfg := server.DefaultConfig()
+	if *configFile != "" {
+		var err error
+		cfg, err = server.LoadConfig(*configFile, cfg)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Seules les options réellement passées écrasent le fichier de configuration
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "p":
+			cfg.Port = *port
+		case "cp":
+			cfg.ControlPort = *controlPort
+		case "control-takeover":
+			cfg.ControlTakeover = *controlTakeover
+		case "root":
+			cfg.Root = *root
+		case "tree-max":
+			cfg.TreeMaxEntries = *treeMax
+		case "drain-timeout":
+			cfg.DrainTimeout = *drainTimeout
+		case "idle-timeout":
+			cfg.IdleTimeout = *idleTimeout
+		case "transfer-timeout":
+			cfg.TransferTimeout = *transferTimeout
+		case "max-session-duration":
+			cfg.MaxSessionDuration = *maxSessionDuration
+		case "max-sessions":
+			cfg.MaxSessions = *maxSessions
+		case "max-per-ip":
+			cfg.MaxSessionsPerIP = *maxPerIP
+		case "max-per-user":
+			cfg.MaxSessionsPerUser = *maxPerUser
+		case "cert":
+			cfg.CertFile = *certFile
+		case "key":
+			cfg.KeyFile = *keyFile
+		case "ca":
+			cfg.CAFile = *caFile
+		case "control-mtls":
+			cfg.ControlMTLS = *controlMTLS
+		case "users":
+			cfg.UsersFile = *usersFile
+		case "audit":
+			cfg.AuditFile = *auditFile
+		}
+	})
+
+	if cfg.ControlTakeover != server.ReprendreRefuser && cfg.ControlTakeover != server.ReprendreRemplacer {
+		slog.Error("Option -control-takeover invalide : " + cfg.ControlTakeover + " (refuse ou replace)")
+		os.Exit(1)
+	}
+
+	if cfg.TransferTimeout <= 0 {
+		slog.Error("Option -transfer-timeout invalide : " + strconv.Itoa(cfg.TransferTimeout) + " (nombre de secondes positif)")
+		os.Exit(1)
+	}
+
+	return cfg
+}
+
+// afficherEmpreinte lit un mot de passe sur l'entrée standard et affiche son empreinte bcrypt,
+// à copier dans le champ "hash" du fichier des utilisateurs.
+func afficherEmpreinte() {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	hash, err := server.HashPassword(strings.TrimRight(password, "\r\n"))
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	fmt.Println(hash)
+	os.Exit(0)
+}
+
+// surveillerSignaux arrête le serveur (annulation du contexte retourné) à la réception de SIGINT ou SIGTERM,
+// avec la même procédure que TERMINATE : les transferts en cours se terminent et les clients sont prévenus.
+// Un second signal quitte immédiatement.
+func surveillerSignaux() context.Context {
+	ctx, arreter := context.WithCancelCause(context.Background())
+	signaux := make(chan os.Signal, 2)
+	signal.Notify(signaux, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signaux
+		slog.Info("Signal " + sig.String() + " reçu, arrêt du serveur (un second signal force l'arrêt immédiat)")
+		arreter(fmt.Errorf("signal %s", sig))
+
+		sig = <-signaux
+		slog.Warn("Signal " + sig.String() + " reçu à nouveau, arrêt immédiat")
+		os.Exit(1)
+	}()
+	return ctx
 }
 
 func main() {
-	port, controlPort := parseArgs()
-	server.RunServer(port, controlPort)
+	cfg := parseArgs()
+	if err := server.RunServer(surveillerSignaux(), &cfg); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
 }
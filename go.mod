@@ -1,3 +1,8 @@
 module gitlab.univ-nantes.fr/iutna.info2.r305/proj
 
 go 1.24.9
+
+require (
+	golang.org/x/crypto v0.43.0
+	golang.org/x/text v0.30.0
+)
@@ -3,14 +3,17 @@ package client
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"net"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
@@ -18,13 +21,49 @@ import (
 // Remote conserve l'adresse du serveur utilisé (utile pour détecter le "port de contrôle").
 var Remote string
 
+// GetRetries : nombre de nouvelles tentatives d'un GET dont l'empreinte SHA-256 ne correspond pas.
+var GetRetries = 1
+
+// Features : fonctionnalités annoncées au serveur (toutes celles du paquet proto par défaut).
+var Features = p.SupportedFeatures
+
+// Formats d'affichage des listes LIST et TREE (option -o).
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// OutputFormat : format d'affichage des listes ; en JSON, la liste du serveur est écrite telle quelle sur la sortie standard.
+var OutputFormat = OutputText
+
+// invite retourne le flux des invites de saisie : en sortie JSON, la sortie standard est réservée aux listes.
+func invite() io.Writer {
+	if OutputFormat == OutputJSON {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// KeepaliveInterval : intervalle des NOOP envoyés pendant que l'utilisateur saisit sa commande (0 : jamais).
+// Remplacé par la moitié du délai d'inactivité annoncé par le serveur, s'il en annonce un (voir reglerKeepalive).
+var KeepaliveInterval = 60 * time.Second
+
+// Capacites : version et fonctionnalités négociées avec le serveur à l'ouverture de la session.
+var Capacites p.Capabilities
+
 // Run tente de se connecter au serveur distant et lance la boucle cliente.
-// remote doit être de la forme "host:port".
-func Run(remote string) {
+// remote doit être de la forme "host:port" ; tlsConfig est nil pour une connexion en clair.
+func Run(remote string, tlsConfig *tls.Config) {
 	log.Println(remote)
 	Remote = remote
 
-	c, err := net.Dial("tcp", remote)
+	var c net.Conn
+	var err error
+	if tlsConfig != nil {
+		c, err = tls.Dial("tcp", remote, tlsConfig)
+	} else {
+		c, err = net.Dial("tcp", remote)
+	}
 	if err != nil {
 		// message spécifique pour le port de contrôle (3334)
 		if strings.Contains(remote, "3334") {
@@ -42,7 +81,8 @@ func Run(remote string) {
 }
 
 // RunClient gère la session avec le serveur.
-// Le protocole ici est séquentiel : on attend "hello", on envoie "start", on attend "ok", puis boucle de commande.
+// Le protocole ici est séquentiel : on attend l'accueil (220), on envoie "start", on attend 200, puis boucle de commande.
+// Chaque réponse du serveur commence par un code (voir proto.Code) : le client décide d'après ce code.
 func RunClient(conn net.Conn) {
 	defer func(conn net.Conn) {
 		err := conn.Close()
@@ -52,29 +92,52 @@ func RunClient(conn net.Conn) {
 	}(conn)
 	log.Println("Connecté au serveur:", conn.RemoteAddr().String())
 
-	reader := bufio.NewReader(conn) // lecture depuis la connexion
-	writer := bufio.NewWriter(conn) // écriture (nécessaire pour p.Send_message)
-	posActuelle := "Docs"           // position locale dans l'arbre de fichiers (répertoire courant)
+	c := p.NewConn(conn) // requêtes, réponses et données échangées avec le serveur
 
-	// Étape 1 : Attendre le message "hello" du serveur
-	msg, err := p.Receive_message(conn, reader)
+	// Étape 1 : Attendre le message "220 <version> <fonctionnalités> <position>" du serveur
+	reponse, err := c.ReceiveResponse()
 	if err != nil {
 		// Gestion simple des erreurs, on loggue et on quitte la fonction
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de la réception de 'hello':", err)
+		} else {
+			// ex : certificat client refusé par le serveur en mTLS
+			log.Println("Erreur lors de la réception de 'hello':", err)
 		}
 		return
 	}
 
-	if strings.TrimSpace(msg) != "hello" {
+	// posActuelle : position affichée dans l'arbre de fichiers, tenue à jour par le serveur
+	if reponse.Code == p.CodeControlBusy || reponse.Code == p.CodeShuttingDown || reponse.Code == p.CodeTooManyConns {
+		// Port de contrôle déjà occupé par une autre session, serveur en cours d'arrêt ou trop de connexions
+		log.Println("Connexion refusée par le serveur :", reponse.Text)
+		return
+	}
+	if reponse.Code != p.CodeHello {
 		// Si le serveur n'a pas envoyé ce qu'on attend, on arrête le protocole
-		log.Println("Protocole échoué : Attendu 'hello', reçu:", strings.TrimSpace(msg))
+		log.Println("Protocole échoué : Attendu '220 <version> <fonctionnalités> <position>', reçu:", reponse.Code, reponse.Text)
+		return
+	}
+	serveur, posActuelle, err := p.ParseCapabilities(reponse.Text)
+	if err != nil || posActuelle == "" {
+		log.Println("Protocole échoué : accueil du serveur invalide:", reponse.Text)
+		return
+	}
+
+	reglerKeepalive(serveur)
+
+	// Le client retient la version et les fonctionnalités communes ; le serveur fait le même calcul
+	local := p.Capabilities{Version: p.ProtocolVersion, Features: Features}
+	Capacites, err = p.Negotiate(local, serveur)
+	if err != nil {
+		log.Println("Serveur incompatible (serveur en", serveur.String()+", client en", local.String()+") :", err)
 		return
 	}
+	slog.Debug("Protocole négocié : " + Capacites.String())
 
-	// Étape 2 : Le client répond "start"
-	if err := p.Send_message(conn, writer, "start"); err != nil {
+	// Étape 2 : Le client répond "start <version> <fonctionnalités>"
+	if err := c.SendRequest(p.NewRequest("start", strings.Fields(local.String())...)); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de l'envoi de 'start':", err)
@@ -82,8 +145,8 @@ func RunClient(conn net.Conn) {
 		return
 	}
 
-	// Étape 3 : Attendre le message "ok" du serveur
-	msg, err = p.Receive_message(conn, reader)
+	// Étape 3 : Attendre la réponse 200 du serveur
+	reponse, err = c.ReceiveResponse()
 	if err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
@@ -92,24 +155,46 @@ func RunClient(conn net.Conn) {
 		return
 	}
 
-	if strings.TrimSpace(msg) != "ok" {
-		log.Println("Protocole échoué : Attendu 'ok' (après start), reçu:", strings.TrimSpace(msg))
+	reader2 := bufio.NewReader(os.Stdin) // lecture des commandes utilisateur
+
+	if reponse.Code == p.CodeAuthRequired {
+		// Étape 3 bis : le serveur exige une authentification USER/PASS
+		posActuelle = LoginClient(c, reader2)
+		if posActuelle == "" {
+			return
+		}
+	} else if reponse.Code == p.CodeVersionRefused || reponse.Code == p.CodeControlBusy {
+		// Protocole incompatible, ou port de contrôle occupé par une autre session
+		log.Println("Le serveur a refusé la session :", reponse.Text)
+		return
+	} else if reponse.Code != p.CodeOK {
+		log.Println("Protocole échoué : Attendu 200 (après start), reçu:", reponse.Code, reponse.Text)
 		return
 	}
 
-	reader2 := bufio.NewReader(os.Stdin) // lecture des commandes utilisateur
-
-	// Étape 4: boucle de commandes utilisateur
+	// Étape 4: boucle de commandes utilisateur ; la saisie est lue à part pour maintenir la session (NOOP)
+	saisies := lireSaisies(reader2)
 	for {
-		fmt.Print("\nVous êtes dans ", posActuelle, "\nEntrez une commande à envoyer au serveur (ou 'end' pour terminer) : ")
-		line, err := reader2.ReadString('\n')
-		if err != nil {
-			log.Println("Erreur lecture stdin:", err)
+		fmt.Fprint(invite(), "\nVous êtes dans ", posActuelle, "\nEntrez une commande à envoyer au serveur (ou 'end' pour terminer) : ")
+		entree, ok := attendreSaisie(c, saisies)
+		if !ok {
+			return
+		}
+		if entree.err != nil {
+			log.Println("Erreur lecture stdin:", entree.err)
 			break
 		}
-		line = strings.TrimSpace(line)
+		line := strings.TrimSpace(entree.ligne)
 
-		var split = strings.Split(line, " ")
+		// Un nom contenant des espaces s'écrit entre guillemets : GET "rapport final.pdf"
+		split, err := p.SplitArgs(line)
+		if err != nil {
+			log.Println("Commande mal formée :", err)
+			continue
+		}
+		if len(split) == 0 {
+			split = []string{""} // ligne vide : traitée comme une commande inconnue
+		}
 		command := strings.ToUpper(split[0])
 		// Déterminer si c'est le port de contrôle (port spécial pour certaines commandes)
 		isControlPort := strings.Contains(Remote, "3334")
@@ -125,24 +210,64 @@ func RunClient(conn net.Conn) {
 			fmt.Println(strings.Trim(fmt.Sprint(p.GetHistorique()), "[]"))
 			continue
 
-			// GET <filename> : on ajoute la position actuelle au tableau pour que Getclient sache où chercher
+			// GET <filename> : le serveur cherche le fichier depuis la position qu'il tient pour la session
 		case command == "GET" && !isControlPort && len(split) == 2:
-			split = append(split, posActuelle)
-			if !Getclient(conn, split, writer, reader) {
+			if !Getclient(c, split) {
+				return
+			}
+
+			// SUM <filename> : empreinte SHA-256 d'un fichier du serveur
+		case command == "SUM" && !isControlPort && len(split) == 2:
+			if !SumClient(c, split) {
+				return
+			}
+
+			// PUT <fichier> [-f] : envoie un fichier local dans la position actuelle du serveur
+		case command == "PUT" && !isControlPort && (len(split) == 2 || len(split) == 3):
+			if !PutClient(c, split) {
+				return
+			}
+
+			// DELETE <file> : supprime un fichier du serveur
+		case command == "DELETE" && len(split) == 2:
+			if !DeleteClient(c, split) {
+				return
+			}
+
+			// RENAME <source> <destination> : renomme ou déplace un fichier ou un dossier du serveur
+		case command == "RENAME" && len(split) == 3:
+			if !RenameClient(c, split) {
+				return
+			}
+
+			// MKDIR <dir> : crée un dossier sur le serveur
+		case command == "MKDIR" && len(split) == 2:
+			if !MkdirClient(c, split) {
+				return
+			}
+
+			// RMDIR <dir> [-r] : supprime un dossier vide, ou avec son contenu
+		case command == "RMDIR" && (len(split) == 2 || (len(split) == 3 && split[2] == "-r")):
+			if !RmdirClient(c, split) {
 				return
 			}
 
-			// LIST <dir> : renvoie la liste des fichiers
-		case command == "LIST":
-			split = append(split, posActuelle)
-			if !ListClient(conn, split, writer, reader) {
+			// LIST [dir] : renvoie la liste des fichiers
+		case command == "LIST" && len(split) <= 3:
+			if !ListClient(c, split) {
+				return
+			}
+
+			// MLSD [dir] [-t|-S] : liste détaillée, triée par date ou par taille
+		case command == "MLSD" && len(split) <= 3:
+			if !MlsdClient(c, split) {
 				return
 			}
 
 			// HELP : le client reçoit la liste des commandes qu'il peut effectuer
 		case command == "HELP":
-			msg := "Help " + strconv.FormatBool(slog.Default().Enabled(context.Background(), slog.LevelDebug))
-			if err := p.Send_message(conn, writer, msg); err != nil {
+			debug := strconv.FormatBool(slog.Default().Enabled(context.Background(), slog.LevelDebug))
+			if err := c.SendRequest(p.NewRequest("Help", debug)); err != nil {
 				var netErr net.Error
 				if errors.As(err, &netErr) && netErr.Timeout() {
 					log.Println("Timeout lors de l'envoi de 'help':", err)
@@ -150,7 +275,7 @@ func RunClient(conn net.Conn) {
 				return
 			}
 
-			msg, err = p.Receive_message(conn, reader)
+			aide, err := c.ReceiveResponse()
 			if err != nil {
 				var netErr net.Error
 				if errors.As(err, &netErr) && netErr.Timeout() {
@@ -158,64 +283,64 @@ func RunClient(conn net.Conn) {
 				}
 				return
 			}
-			log.Println(msg)
+			log.Println(aide.Text)
 
 			// commande spéciale disponible seulement sur le port de contrôle
 			// TERMINATE : permet d'éteindre le serveur et de déconnecter les autres clients
 			// une fois leurs requêtes terminées
 		case command == "TERMINATE" && isControlPort:
-			if !TerminateClient(conn, writer, reader) {
+			if !TerminateClient(c) {
 				return
 			}
 			return // Fermer la connexion après terminate
 
 			// HIDE <file> : permet de cacher un fichier visible
 		case command == "HIDE" && isControlPort && len(split) == 2:
-			split = append(split, posActuelle)
-			if !HideClient(conn, split, writer, reader) {
+			if !HideClient(c, split) {
 				return
 			}
 
 			// REVEAL <file> : permet de révéler un fichier caché
 		case command == "REVEAL" && isControlPort && len(split) == 2:
-			split = append(split, posActuelle)
-			if !RevealClient(conn, split, writer, reader) {
+			if !RevealClient(c, split) {
+				return
+			}
+
+			// HIDDEN : liste les fichiers cachés
+		case command == "HIDDEN" && isControlPort:
+			if !HiddenClient(c, split) {
+				return
+			}
+
+			// STATUS : état du serveur, sessions ouvertes et limites de connexions
+		case command == "STATUS" && isControlPort:
+			if !StatusClient(c) {
 				return
 			}
 
 			// TREE : affiche l'arborescence
 		case command == "TREE":
-			split = append(split, posActuelle)
-			if !treeClient(conn, split, writer, reader) {
+			if !treeClient(c, split, posActuelle) {
 				return
 			}
 
-			// GOTO <target> : change la position locale en interrogeant le serveur
-		case command == "GOTO":
-			split = append(split, posActuelle)
-
-			nouvellePos := GOTOClient(conn, posActuelle, split, writer, reader)
+			// GOTO <target> : le serveur change la position de la session et renvoie la nouvelle
+		case command == "GOTO" && len(split) == 2:
+			nouvellePos := GOTOClient(c, split)
 
-			// 2. Traitement de la réponse NO! (Échec de navigation ou déjà là)
+			// Traitement de la réponse NO! (Échec de navigation ou sortie de la racine)
 			if nouvellePos == "NO!" {
 				log.Println("Naviguation impossible !")
 				continue
 			}
 
-			// 3. Traitement du Succès de navigation (nouvellePos contient le chemin cible ou le parent)
-
-			if split[1] == ".." {
-				// Remonter d'un niveau. nouvellePos contient le chemin parent (ex: Docs/docs)
-				posActuelle = nouvellePos
-			} else {
-				// Descendre dans un sous-dossier. nouvellePos contient le nom du sous-dossier (ex: docs)
-				posActuelle = posActuelle + "/" + nouvellePos
-			}
+			// Succès de navigation : nouvellePos contient la position donnée par le serveur (ex: Docs/docs)
+			posActuelle = nouvellePos
 
 			// Commande inconnue : informer le serveur et afficher la réponse
 		default:
 
-			if err := p.Send_message(conn, writer, "Unknown"); err != nil {
+			if err := c.SendRequest(p.NewRequest("Unknown")); err != nil {
 				var netErr net.Error
 				if errors.As(err, &netErr) && netErr.Timeout() {
 					log.Println("Timeout lors de l'envoi de 'unknown':", err)
@@ -223,7 +348,7 @@ func RunClient(conn net.Conn) {
 				return
 			}
 
-			msg, err = p.Receive_message(conn, reader)
+			inconnue, err := c.ReceiveResponse()
 			if err != nil {
 				var netErr net.Error
 				if errors.As(err, &netErr) && netErr.Timeout() {
@@ -231,12 +356,12 @@ func RunClient(conn net.Conn) {
 				}
 				return
 			}
-			log.Println(msg)
+			log.Println(inconnue.Text)
 		}
 	}
 
 	// Étape 5 : Le client répond "end" pour clore la session proprement
-	if err := p.Send_message(conn, writer, "end"); err != nil {
+	if err := c.SendRequest(p.NewRequest("end")); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de l'envoi de 'end':", err)
@@ -244,8 +369,8 @@ func RunClient(conn net.Conn) {
 		return
 	}
 
-	// Étape 6 : Attendre le message "ok" final du serveur
-	msg, err = p.Receive_message(conn, reader)
+	// Étape 6 : Attendre la réponse 221 finale du serveur
+	reponse, err = c.ReceiveResponse()
 	if err != nil {
 		// La déconnexion immédiate du serveur après l'envoi du "ok" est possible
 		var netErr net.Error
@@ -255,8 +380,8 @@ func RunClient(conn net.Conn) {
 		return
 	}
 
-	if strings.TrimSpace(msg) != "ok" {
-		log.Println("Protocole échoué : Attendu 'ok' final, reçu:", strings.TrimSpace(msg))
+	if reponse.Code != p.CodeBye {
+		log.Println("Protocole échoué : Attendu 221 final, reçu:", reponse.Code, reponse.Text)
 		return
 	}
 
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// DeleteClient demande au serveur de supprimer un fichier (pas un dossier, voir RmdirClient)
+// split : [ "DELETE", "<filename>" ], le nom étant relatif à la position tenue par le serveur
+func DeleteClient(c *p.Conn, split []string) bool {
+	if err := c.SendRequest(p.NewRequest("DELETE", split[1])); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande DELETE:", err)
+		}
+		return false
+	}
+
+	// Attendre la réponse du serveur
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse DELETE:", err)
+		}
+		return false
+	}
+
+	if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+	} else if reponse.Code == p.CodeFileUnknown {
+		log.Println("Fichier introuvable sur le serveur")
+	} else if reponse.Code == p.CodeNameRefused {
+		log.Println("Suppression refusée :", reponse.Text)
+	} else if reponse.Code == p.CodeLocalError {
+		log.Println("Erreur du serveur :", reponse.Text)
+	} else if reponse.Code == p.CodeOK {
+		log.Printf("Fichier '%s' supprimé avec succès\n", split[1])
+	} else {
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+	}
+
+	return true
+}
@@ -1,20 +1,70 @@
 package client
 
 import (
-	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
 	"log"
 	"net"
 	"os"
-	"strings"
+	"path/filepath"
+	"strconv"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
 // Getclient gère la commande GET : demander un fichier, recevoir son contenu et sauvegarder localement
-// splitGET : [ "GET", "<filename>", "<position>" ]
-func Getclient(conn net.Conn, splitGET []string, writer *bufio.Writer, reader *bufio.Reader) bool {
-	if err := p.Send_message(conn, writer, "GET"+" "+splitGET[1]+" "+splitGET[2]); err != nil {
+// splitGET : [ "GET", "<filename>" ], le nom étant relatif à la position tenue par le serveur
+// Le contenu est d'abord écrit dans "<filename>.part" : si ce fichier existe déjà (transfert interrompu),
+// le client envoie "GET <filename> <offset>" pour ne recevoir que les octets manquants.
+// L'empreinte SHA-256 envoyée par le serveur après les données est comparée à celle du fichier reçu :
+// en cas de différence, le fichier partiel est supprimé et le GET est retenté jusqu'à GetRetries fois.
+func Getclient(c *p.Conn, splitGET []string) bool {
+	return getclient(c, splitGET, GetRetries)
+}
+
+// getclient réalise un GET ; essais est le nombre de nouvelles tentatives restantes après une empreinte invalide.
+func getclient(c *p.Conn, splitGET []string, essais int) bool {
+	// Le fichier est sauvegardé avec le même nom, dans le dossier de travail
+	var nomLocal = p.NormalizeName(filepath.Base(splitGET[1]))
+	var nomPartiel = nomLocal + ".part"
+
+	var offset int64
+	if info, err := os.Stat(nomPartiel); err == nil && info.Mode().IsRegular() {
+		offset = info.Size()
+	}
+	// Sans reprise négociée, un fichier partiel ne peut pas être complété : on repart de zéro
+	if offset > 0 && !Capacites.Has(p.FeatureResume) {
+		log.Println("Reprise non supportée par le serveur, nouveau téléchargement depuis le début")
+		if err := os.Remove(nomPartiel); err != nil {
+			log.Println("Erreur lors de la suppression du fichier partiel:", err)
+			return true
+		}
+		offset = 0
+	}
+
+	// L'empreinte porte sur le fichier complet : le début déjà reçu est relu avant la demande,
+	// pour ne pas faire attendre le serveur une fois le transfert commencé
+	hasher := sha256.New()
+	if offset > 0 {
+		if err := hasherDebut(hasher, nomPartiel, offset); err != nil {
+			log.Println("Erreur lors de la lecture du fichier partiel, nouveau téléchargement depuis le début:", err)
+			if err := os.Remove(nomPartiel); err != nil {
+				log.Println("Erreur lors de la suppression du fichier partiel:", err)
+				return true
+			}
+			offset = 0
+			hasher.Reset()
+		}
+	}
+
+	req := p.NewRequest("GET", splitGET[1])
+	if offset > 0 {
+		log.Printf("Fichier partiel '%s' trouvé, reprise à l'octet %d\n", nomPartiel, offset)
+		req.Args = append(req.Args, strconv.FormatInt(offset, 10))
+	}
+	if err := c.SendRequest(req); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de l'envoi de la commande GET:", err)
@@ -23,7 +73,7 @@ func Getclient(conn net.Conn, splitGET []string, writer *bufio.Writer, reader *b
 	}
 
 	// Attend la réponse du serveur
-	var response, err = p.Receive_message(conn, reader)
+	reponse, err := c.ReceiveResponse()
 	if err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
@@ -31,15 +81,18 @@ func Getclient(conn net.Conn, splitGET []string, writer *bufio.Writer, reader *b
 		}
 		return false
 	}
-	response = strings.TrimSpace(response)
-	log.Println(response)
+	log.Println(reponse.Code, reponse.Text)
 
-	// fichier introuvable
-	if response == "FileUnknown" {
+	// droits insuffisants : le serveur n'attend pas de confirmation
+	if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+
+		// fichier introuvable
+	} else if reponse.Code == p.CodeFileUnknown {
 		log.Println("Fichier introuvable sur le serveur")
 
-		// Envoie "OK" pour confirmer la réception de FileUnknown
-		if err := p.Send_message(conn, writer, "OK"); err != nil {
+		// Envoie "OK" pour confirmer la réception du refus
+		if err := c.SendRequest(p.NewRequest("OK")); err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
 				log.Println("Timeout lors de l'envoi de 'OK':", err)
@@ -47,29 +100,115 @@ func Getclient(conn net.Conn, splitGET []string, writer *bufio.Writer, reader *b
 			return false
 		}
 
-	} else if response == "Start" {
-		// Le serveur envoie ensuite le contenu du fichier
-		data, err := p.Receive_message(conn, reader)
-		if err != nil {
+	} else if reponse.Code == p.CodeBadOffset {
+		// Le fichier partiel est plus grand que le fichier du serveur : il ne lui correspond plus
+		log.Println("Reprise impossible, le fichier partiel ne correspond plus au fichier du serveur")
+
+		if err := c.SendRequest(p.NewRequest("OK")); err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de la lecture du fichier:", err)
+				log.Println("Timeout lors de l'envoi de 'OK':", err)
 			}
 			return false
 		}
 
-		// Sauvegarde le fichier localement avec le même nom
-		err = os.WriteFile(splitGET[1], []byte(data), 0770)
+		if err := os.Remove(nomPartiel); err != nil {
+			log.Println("Erreur lors de la suppression du fichier partiel:", err)
+			return true
+		}
+		log.Println("Nouveau téléchargement depuis le début")
+		return getclient(c, splitGET, essais)
+
+	} else if reponse.Code == p.CodeStart {
+		// "150 <taille>" : le serveur envoie ensuite exactement <taille> octets bruts à partir de l'offset
+		size, err := strconv.ParseInt(reponse.Text, 10, 64)
+		if err != nil || size < 0 {
+			log.Println("Taille de fichier invalide:", reponse.Text)
+			return false
+		}
+
+		// Les octets reçus sont ajoutés à la fin du fichier partiel
+		fichier, err := os.OpenFile(nomPartiel, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0770)
 		if err != nil {
-			log.Println("Erreur lors de la sauvegarde du fichier:", err)
+			log.Println("Erreur lors de la création du fichier:", err)
+			// Les octets annoncés et l'empreinte sont tout de même lus pour garder le protocole synchronisé
+			if err := c.ReceiveData(io.Discard, size); err != nil {
+				log.Println("Erreur lors de la réception du fichier:", err)
+				return false
+			}
+			if Capacites.Has(p.FeatureChecksum) {
+				if _, err := recevoirEmpreinte(c); err != nil {
+					log.Println("Erreur lors de la réception de l'empreinte:", err)
+					return false
+				}
+			}
+			if err := c.SendRequest(p.NewRequest("OK")); err != nil {
+				log.Println("Erreur lors de l'envoi de 'OK':", err)
+				return false
+			}
+			return true
+		}
+
+		err = c.ReceiveData(io.MultiWriter(fichier, hasher), size)
+		if errClose := fichier.Close(); err == nil {
+			err = errClose
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Println("Timeout lors de la lecture du fichier:", err)
+			}
+			log.Println("Erreur lors de la réception du fichier:", err)
+			// Le fichier partiel est conservé : le prochain GET reprendra là où le transfert s'est arrêté
+			log.Printf("Transfert interrompu, '%s' est conservé pour une reprise\n", nomPartiel)
 			return false
 		}
 
-		log.Printf("Fichier '%s' reçu et sauvegardé (%d octets)\n", splitGET[1], len(data))
-		log.Printf("Contenu du fichier '%s':\n%s\n", splitGET[1], data)
+		recu := hex.EncodeToString(hasher.Sum(nil))
+
+		// Sans la fonctionnalité "checksum", le serveur n'envoie pas d'empreinte à vérifier
+		if Capacites.Has(p.FeatureChecksum) {
+			// "213 <sha256>" : empreinte du fichier complet calculée par le serveur
+			somme, err := recevoirEmpreinte(c)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					log.Println("Timeout lors de la réception de l'empreinte:", err)
+				}
+				return false
+			}
+
+			if somme.Code != p.CodeChecksum || somme.Text != recu {
+				log.Printf("Empreinte invalide pour '%s' : attendu %s, reçu %s\n", nomLocal, somme.Text, recu)
+				// Le fichier reçu est corrompu : il est supprimé pour repartir de zéro
+				if err := os.Remove(nomPartiel); err != nil {
+					log.Println("Erreur lors de la suppression du fichier corrompu:", err)
+				}
+				if err := c.SendRequest(p.NewRequest("ChecksumMismatch")); err != nil {
+					var netErr net.Error
+					if errors.As(err, &netErr) && netErr.Timeout() {
+						log.Println("Timeout lors de l'envoi de 'ChecksumMismatch':", err)
+					}
+					return false
+				}
+				if essais > 0 {
+					log.Println("Nouvelle tentative de téléchargement, essais restants :", essais)
+					return getclient(c, splitGET, essais-1)
+				}
+				log.Println("Échec du téléchargement après vérification de l'empreinte")
+				return true
+			}
+		}
+
+		// Le fichier complet et vérifié prend sa place définitive
+		if err := os.Rename(nomPartiel, nomLocal); err != nil {
+			log.Println("Erreur lors de la sauvegarde du fichier:", err)
+		} else {
+			log.Printf("Fichier '%s' reçu et sauvegardé (%d octets reçus, sha256 %s)\n", nomLocal, size, recu)
+		}
 
 		// Envoie "OK" pour confirmer la bonne réception
-		if err := p.Send_message(conn, writer, "OK"); err != nil {
+		if err := c.SendRequest(p.NewRequest("OK")); err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
 				log.Println("Timeout lors de l'envoi de 'OK':", err)
@@ -78,8 +217,19 @@ func Getclient(conn net.Conn, splitGET []string, writer *bufio.Writer, reader *b
 		}
 	} else {
 		// Toute autre réponse est imprévue
-		log.Println("Réponse inattendue du serveur:", response)
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
 	}
 
 	return true
 }
+
+// hasherDebut ajoute les taille premiers octets du fichier path à l'empreinte en cours de calcul.
+func hasherDebut(hasher io.Writer, path string, taille int64) error {
+	fichier, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fichier.Close()
+	_, err = io.CopyN(hasher, fichier, taille)
+	return err
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// HiddenClient demande la liste des fichiers cachés par HIDE (commande disponible sur le port de contrôle)
+// split : [ "HIDDEN" ] ; les chemins affichés sont relatifs à la racine servie
+func HiddenClient(c *p.Conn, split []string) bool {
+	if err := c.SendRequest(p.NewRequest("HIDDEN")); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande HIDDEN:", err)
+		}
+		return false
+	}
+
+	// Attendre la réponse du serveur
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse HIDDEN:", err)
+		}
+		return false
+	}
+
+	if reponse.Code == p.CodeStart {
+		log.Println("\n=== Fichiers cachés ===")
+		nombre, ok := recevoirListe(c, "HIDDEN", func(element string) error {
+			e, err := p.ParseEntry(element)
+			if err != nil {
+				return err
+			}
+			if e.Size < 0 {
+				log.Println(e.Name, "(introuvable sur le disque)")
+			} else {
+				log.Println(e.Name, e.Size)
+			}
+			return nil
+		})
+		log.Println("Nombre d'éléments :", nombre)
+		log.Println("=======================")
+		if !ok {
+			return false
+		}
+	} else if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+		return true
+	} else {
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+	}
+
+	return true
+}
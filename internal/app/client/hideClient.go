@@ -1,20 +1,17 @@
 package client
 
 import (
-	"bufio"
 	"errors"
 	"log"
 	"net"
-	"strings"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
 // HideClient demande au serveur de cacher un fichier (commande disponible sur le port de contrôle)
-// split : [ "HIDE", "<filename>", "<position>" ]
-func HideClient(conn net.Conn, split []string, writer *bufio.Writer, reader *bufio.Reader) bool {
-	command := "HIDE " + split[1] + " " + split[2]
-	if err := p.Send_message(conn, writer, command); err != nil {
+// split : [ "HIDE", "<filename>" ], le nom étant relatif à la position tenue par le serveur
+func HideClient(c *p.Conn, split []string) bool {
+	if err := c.SendRequest(p.NewRequest("HIDE", split[1])); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de l'envoi de la commande HIDE:", err)
@@ -23,7 +20,7 @@ func HideClient(conn net.Conn, split []string, writer *bufio.Writer, reader *buf
 	}
 
 	// Attendre la réponse du serveur
-	response, err := p.Receive_message(conn, reader)
+	reponse, err := c.ReceiveResponse()
 	if err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
@@ -31,14 +28,15 @@ func HideClient(conn net.Conn, split []string, writer *bufio.Writer, reader *buf
 		}
 		return false
 	}
-	response = strings.TrimSpace(response)
 
-	if response == "FileUnknown" {
+	if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+	} else if reponse.Code == p.CodeFileUnknown {
 		log.Println("Fichier introuvable sur le serveur")
-	} else if response == "OK" {
+	} else if reponse.Code == p.CodeOK {
 		log.Printf("Fichier '%s' caché avec succès\n", split[1])
 	} else {
-		log.Println("Réponse inattendue du serveur:", response)
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
 	}
 
 	return true
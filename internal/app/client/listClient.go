@@ -1,19 +1,27 @@
 package client
 
 import (
-	"bufio"
 	"errors"
+	"fmt"
 	"log"
 	"net"
-	"strings"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
 // ListClient demande la liste des fichiers et l'affiche.
-// split : [ "LIST", "<dir>", "<posActuelle>" ]
-func ListClient(conn net.Conn, split []string, writer *bufio.Writer, reader *bufio.Reader) bool {
-	if err := p.Send_message(conn, writer, "List "+split[1]); err != nil {
+// split : [ "LIST" ] ou [ "LIST", "<dir>" ], le dossier étant relatif à la position tenue par le serveur,
+// suivi de "-json" pour afficher la liste JSON du serveur (comme avec l'option -o json du client)
+func ListClient(c *p.Conn, split []string) bool {
+	args, enJSON, ok := optionJSON(split[1:])
+	if !ok {
+		return true
+	}
+	var req = p.NewRequest("List", args...)
+	if enJSON {
+		req.Args = append(req.Args, p.OptionJSON)
+	}
+	if err := c.SendRequest(req); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de l'envoi de la commande LIST:", err)
@@ -22,7 +30,7 @@ func ListClient(conn net.Conn, split []string, writer *bufio.Writer, reader *buf
 	}
 
 	// Attend la réponse du serveur
-	var response, err = p.Receive_message(conn, reader)
+	reponse, err := c.ReceiveResponse()
 	if err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
@@ -30,46 +38,100 @@ func ListClient(conn net.Conn, split []string, writer *bufio.Writer, reader *buf
 		}
 		return false
 	}
-	response = strings.TrimSpace(response)
 
-	if response == "Start" {
-		// Le serveur va envoyer la liste ; on confirme par "OK"
-		if err := p.Send_message(conn, writer, "OK"); err != nil {
-			var netErr net.Error
-			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de l'envoi de 'OK':", err)
-			}
+	if reponse.Code == p.CodeStart {
+		// Le serveur envoie la liste élément par élément : chacun est affiché dès sa réception
+		var nombre int
+		var ok bool
+		if enJSON {
+			// Chaque objet JSON est écrit tel quel sur la sortie standard (JSON Lines), pour être consommé par un script
+			_, ok = recevoirListe(c, "LIST", func(element string) error {
+				fmt.Println(element)
+				return nil
+			})
+		} else {
+			log.Println("\n=== Liste des fichiers disponibles ===")
+			nombre, ok = recevoirListe(c, "LIST", func(element string) error {
+				// "<nom> <taille>" : nom entre guillemets s'il contient des espaces
+				e, err := p.ParseEntry(element)
+				if err == nil {
+					log.Println(e.Name, e.Size)
+				}
+				return err
+			})
+			log.Println("Nombre d'éléments :", nombre)
+			log.Println("=====================================")
+		}
+		if !ok {
 			return false
 		}
+	} else if reponse.Code == p.CodeFileUnknown {
+		log.Println("Dossier introuvable sur le serveur")
+	} else if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+		return true
+	} else {
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+	}
+
+	return true
+}
+
+// optionJSON retire l'option -json des arguments saisis et indique si la liste doit être demandée en JSON
+// (option saisie ou client lancé avec -o json). ok vaut false si le serveur n'a pas négocié la fonctionnalité "json".
+func optionJSON(args []string) (reste []string, enJSON bool, ok bool) {
+	reste, enJSON = p.CutOption(args, p.OptionJSON)
+	enJSON = enJSON || OutputFormat == OutputJSON
+	if enJSON && !Capacites.Has(p.FeatureJSON) {
+		log.Println("Sortie JSON non supportée par le serveur (fonctionnalité json non négociée)")
+		return reste, enJSON, false
+	}
+	return reste, enJSON, true
+}
+
+// recevoirListe confirme le début d'une liste (LIST, TREE, MLSD) par "OK", puis reçoit ses éléments "151"
+// jusqu'au marqueur de fin "226 <N>", en appelant afficher pour chacun dès sa réception.
+// Retourne le nombre d'éléments reçus ; un élément illisible est signalé et ignoré.
+// ok vaut false en cas d'erreur réseau ou de réponse inattendue.
+func recevoirListe(c *p.Conn, commande string, afficher func(element string) error) (nombre int, ok bool) {
+	if err := c.SendRequest(p.NewRequest("OK")); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de 'OK':", err)
+		}
+		return nombre, false
+	}
 
-		data, err := p.Receive_message(conn, reader)
+	for {
+		reponse, err := c.ReceiveResponse()
 		if err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de la lecture de la liste:", err)
+				log.Println("Timeout lors de la lecture de la liste "+commande+":", err)
 			}
-			return false
+			return nombre, false
 		}
 
-		// Le serveur renvoie les éléments séparés par "--"
-		var datas = strings.Split(data, "--")
-		log.Println("\n=== Liste des fichiers disponibles ===")
-		for _, item := range datas {
-			if strings.TrimSpace(item) != "" {
-				log.Println(strings.TrimSpace(item))
+		switch reponse.Code {
+		case p.CodeEntry:
+			if err := afficher(reponse.Text); err != nil {
+				log.Println("Élément invalide reçu du serveur:", err)
 			}
+			nombre++
+		case p.CodeTruncated:
+			// Le serveur a atteint son nombre maximal d'éléments : la liste est partielle
+			log.Println("Attention :", reponse.Text)
+		case p.CodeListing:
+			annonce, err := p.ParseCount(reponse.Text)
+			if err != nil {
+				log.Println("Fin de liste invalide reçue du serveur:", err)
+			} else if annonce != nombre {
+				log.Println("Attention :", nombre, "éléments reçus,", annonce, "annoncés par le serveur")
+			}
+			return nombre, true
+		default:
+			log.Println("Réponse inattendue du serveur pendant la liste "+commande+":", reponse.Code, reponse.Text)
+			return nombre, false
 		}
-		log.Println("=====================================")
-	}
-
-	// Fin de l'opération LIST : on envoie "ok" pour clore l'échange
-	if err := p.Send_message(conn, writer, "ok"); err != nil {
-		var netErr net.Error
-		if errors.As(err, &netErr) && netErr.Timeout() {
-			log.Println("Timeout lors de l'envoi de 'ok' final LIST:", err)
-		}
-		return false
 	}
-
-	return true
 }
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// Username : nom d'utilisateur passé avec -u (vide = demandé à l'utilisateur).
+var Username string
+
+// essaisLogin : nombre de tentatives proposées avant d'abandonner la connexion.
+const essaisLogin = 3
+
+// LoginClient mène l'authentification USER/PASS demandée par le serveur (code 330).
+// Le nom vient de l'option -u ou est demandé, le mot de passe est toujours demandé sur stdin.
+// Retourne la position de départ envoyée par le serveur, ou "" si l'authentification a échoué.
+func LoginClient(c *p.Conn, stdin *bufio.Reader) string {
+	for essai := 0; essai < essaisLogin; essai++ {
+		nom := Username
+		if nom == "" {
+			fmt.Fprint(invite(), "Utilisateur : ")
+			line, err := stdin.ReadString('\n')
+			if err != nil {
+				log.Println("Erreur lecture stdin:", err)
+				return ""
+			}
+			nom = strings.TrimSpace(line)
+		}
+		fmt.Fprint(invite(), "Mot de passe : ")
+		password, err := stdin.ReadString('\n')
+		if err != nil {
+			log.Println("Erreur lecture stdin:", err)
+			return ""
+		}
+		password = strings.TrimRight(password, "\r\n")
+
+		// USER <nom> : le serveur attend ensuite le mot de passe
+		if code, texte := echangeLogin(c, p.NewRequest("USER", nom)); code != p.CodePassRequired {
+			log.Println("Réponse inattendue du serveur:", code, texte)
+			return ""
+		}
+
+		code, texte := echangeLogin(c, p.NewRequest("PASS", password))
+		switch code {
+		case p.CodeLoggedIn:
+			// le texte de la réponse est la position de départ
+			log.Println("Authentifié en tant que", nom)
+			return texte
+		case p.CodeLoginFailed:
+			log.Println("Identifiants invalides")
+		case p.CodeLocked:
+			log.Println("Compte temporairement bloqué après trop d'échecs, réessayez plus tard")
+			return ""
+		case p.CodeAdminRequired:
+			log.Println("Le port de contrôle est réservé aux administrateurs")
+			return ""
+		case p.CodeTooManyConns, p.CodeControlBusy:
+			log.Println("Connexion refusée par le serveur :", texte)
+			return ""
+		default:
+			log.Println("Réponse inattendue du serveur:", code, texte)
+			return ""
+		}
+	}
+
+	log.Println("Trop de tentatives, abandon de la connexion")
+	return ""
+}
+
+// echangeLogin envoie une requête de login et retourne la réponse du serveur (code 0 en cas d'erreur réseau).
+// Les arguments sont mis entre guillemets si besoin : un mot de passe peut contenir des espaces.
+func echangeLogin(c *p.Conn, req p.Request) (p.Code, string) {
+	if err := c.SendRequest(req); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande de login:", err)
+		}
+		return 0, ""
+	}
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse de login:", err)
+		}
+		return 0, ""
+	}
+	return reponse.Code, reponse.Text
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// MkdirClient demande au serveur de créer un dossier
+// split : [ "MKDIR", "<dir>" ], le nom étant relatif à la position tenue par le serveur
+func MkdirClient(c *p.Conn, split []string) bool {
+	if err := c.SendRequest(p.NewRequest("MKDIR", split[1])); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande MKDIR:", err)
+		}
+		return false
+	}
+
+	// Attendre la réponse du serveur
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse MKDIR:", err)
+		}
+		return false
+	}
+
+	if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+	} else if reponse.Code == p.CodeFileUnknown {
+		log.Println("Dossier parent introuvable sur le serveur")
+	} else if reponse.Code == p.CodeFileExists {
+		log.Println("Le dossier existe déjà sur le serveur")
+	} else if reponse.Code == p.CodeNameRefused {
+		log.Println("Nom de dossier refusé par le serveur")
+	} else if reponse.Code == p.CodeLocalError {
+		log.Println("Erreur du serveur :", reponse.Text)
+	} else if reponse.Code == p.CodeOK {
+		log.Printf("Dossier '%s' créé avec succès\n", split[1])
+	} else {
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+	}
+
+	return true
+}
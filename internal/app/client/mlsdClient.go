@@ -0,0 +1,111 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sort"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// MlsdClient demande la liste détaillée d'un dossier et l'affiche dans le style de "ls -l".
+// split : [ "MLSD", ["<dir>"], ["-t" | "-S"] ] ; -t trie par date (plus récent d'abord),
+// -S par taille (plus gros d'abord), sinon les éléments sont affichés dans l'ordre de réception.
+func MlsdClient(c *p.Conn, split []string) bool {
+	if !Capacites.Has(p.FeatureMLSD) {
+		log.Println("MLSD n'est pas supporté par le serveur (fonctionnalité mlsd non négociée)")
+		return true
+	}
+
+	var req = p.NewRequest("MLSD")
+	var tri string
+	for _, arg := range split[1:] {
+		if arg == "-t" || arg == "-S" {
+			tri = arg
+		} else {
+			req.Args = append(req.Args, arg)
+		}
+	}
+	if len(req.Args) > 1 {
+		log.Println("Usage : MLSD [dir] [-t|-S]")
+		return true
+	}
+
+	if err := c.SendRequest(req); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande MLSD:", err)
+		}
+		return false
+	}
+
+	// Attend la réponse du serveur
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse MLSD:", err)
+		}
+		return false
+	}
+
+	if reponse.Code == p.CodeStart {
+		// Sans tri, chaque élément est affiché dès sa réception ; un tri oblige à attendre la fin de la liste
+		var elements []p.FileInfo
+		log.Println("\n=== Liste détaillée ===")
+		nombre, ok := recevoirListe(c, "MLSD", func(element string) error {
+			e, err := p.ParseDetail(element)
+			if err != nil {
+				return err
+			}
+			if tri == "" {
+				afficherDetail(e)
+			} else {
+				elements = append(elements, e)
+			}
+			return nil
+		})
+		trierDetails(elements, tri)
+		for _, e := range elements {
+			afficherDetail(e)
+		}
+		log.Println("Nombre d'éléments :", nombre)
+		log.Println("=======================")
+		if !ok {
+			return false
+		}
+	} else if reponse.Code == p.CodeFileUnknown {
+		log.Println("Dossier introuvable sur le serveur")
+	} else if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+		return true
+	} else {
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+	}
+
+	return true
+}
+
+// trierDetails trie la liste selon l'option tri ("-t" : date, "-S" : taille), du plus grand au plus petit.
+func trierDetails(elements []p.FileInfo, tri string) {
+	switch tri {
+	case "-t":
+		sort.SliceStable(elements, func(i, j int) bool { return elements[i].ModTime.After(elements[j].ModTime) })
+	case "-S":
+		sort.SliceStable(elements, func(i, j int) bool { return elements[i].Size > elements[j].Size })
+	}
+}
+
+// afficherDetail affiche un élément : permissions, propriétaire, taille, date et nom.
+// Les dossiers sont suivis de "/", les éléments cachés (port de contrôle) sont signalés.
+func afficherDetail(e p.FileInfo) {
+	var nom = e.Name
+	if e.Type == p.TypeDir {
+		nom += "/"
+	}
+	if e.Hidden {
+		nom += "  (caché)"
+	}
+	log.Printf("%s %-8s %10d %s %s\n", e.Mode, e.Owner, e.Size, e.ModTime.Local().Format("2006-01-02 15:04"), nom)
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// saisie : ligne lue sur l'entrée standard, ou erreur de lecture (fin de l'entrée).
+type saisie struct {
+	ligne string
+	err   error
+}
+
+// lireSaisies lit les lignes de reader dans sa propre goroutine, jusqu'à la première erreur :
+// la boucle de commandes peut ainsi maintenir la session pendant que l'utilisateur réfléchit.
+func lireSaisies(reader *bufio.Reader) <-chan saisie {
+	saisies := make(chan saisie)
+	go func() {
+		for {
+			ligne, err := reader.ReadString('\n')
+			saisies <- saisie{ligne: ligne, err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return saisies
+}
+
+// reglerKeepalive règle KeepaliveInterval sur la moitié du délai d'inactivité annoncé par le serveur dans son accueil
+// (idle=<secondes>). Sans annonce, ou si les NOOP sont désactivés (-keepalive 0), l'intervalle configuré est conservé.
+func reglerKeepalive(serveur p.Capabilities) {
+	valeur, annonce := serveur.Param(p.ParamIdle)
+	if !annonce || KeepaliveInterval <= 0 {
+		return
+	}
+	secondes, err := strconv.Atoi(valeur)
+	if err != nil || secondes <= 0 {
+		log.Println("Délai d'inactivité annoncé par le serveur invalide:", valeur)
+		return
+	}
+	KeepaliveInterval = time.Duration(secondes) * time.Second / 2
+	slog.Debug("NOOP envoyé toutes les " + KeepaliveInterval.String() + " (délai d'inactivité du serveur : " + valeur + " s)")
+}
+
+// attendreSaisie attend la prochaine ligne saisie par l'utilisateur. Pendant l'attente, NOOP est envoyé toutes les
+// KeepaliveInterval pour que le serveur ne ferme pas la session inactive (si la fonctionnalité "noop" est négociée).
+// ok vaut false si le serveur ne répond plus (session expirée ou connexion perdue).
+func attendreSaisie(c *p.Conn, saisies <-chan saisie) (entree saisie, ok bool) {
+	if KeepaliveInterval <= 0 || !Capacites.Has(p.FeatureNoop) {
+		return <-saisies, true
+	}
+	ticker := time.NewTicker(KeepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case entree = <-saisies:
+			return entree, true
+		case <-ticker.C:
+			if !NoopClient(c) {
+				return entree, false
+			}
+		}
+	}
+}
+
+// NoopClient envoie NOOP au serveur pour maintenir la session, et attend sa réponse 200.
+func NoopClient(c *p.Conn) bool {
+	if err := c.SendRequest(p.NewRequest("NOOP")); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de 'NOOP':", err)
+		}
+		log.Println("Connexion au serveur perdue")
+		return false
+	}
+
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse NOOP:", err)
+		}
+		log.Println("Connexion au serveur perdue")
+		return false
+	}
+
+	switch reponse.Code {
+	case p.CodeOK:
+		slog.Debug("Session maintenue (NOOP)")
+		return true
+	case p.CodeExpired, p.CodeShuttingDown:
+		// Session fermée par le serveur (durée maximale atteinte, arrêt du serveur)
+		log.Println("Session fermée par le serveur :", reponse.Text)
+	default:
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+	}
+	return false
+}
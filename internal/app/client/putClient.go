@@ -0,0 +1,100 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// PutClient gère la commande PUT : envoyer un fichier local au serveur, dans la position tenue par le serveur.
+// split : [ "PUT", "<fichier local>" ] ou [ "PUT", "<fichier local>", "-f" ] pour écraser un fichier existant
+func PutClient(c *p.Conn, split []string) bool {
+	fichier, err := os.Open(split[1])
+	if err != nil {
+		// Erreur locale : rien n'a été envoyé au serveur, la session continue
+		log.Println("Impossible d'ouvrir le fichier local:", err)
+		return true
+	}
+	defer fichier.Close()
+
+	info, err := fichier.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		log.Println("Le fichier local n'est pas un fichier ordinaire:", split[1])
+		return true
+	}
+
+	// Le fichier est créé côté serveur sous le même nom (normalisé en NFC), sans le chemin local
+	req := p.NewRequest("PUT", p.NormalizeName(filepath.Base(split[1])), strconv.FormatInt(info.Size(), 10))
+	if len(split) == 3 && split[2] == "-f" {
+		req.Args = append(req.Args, "-f")
+	}
+	if err := c.SendRequest(req); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande PUT:", err)
+		}
+		return false
+	}
+
+	// Attend la réponse du serveur
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse PUT:", err)
+		}
+		return false
+	}
+
+	if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+		return true
+	} else if reponse.Code == p.CodeFileExists {
+		log.Println("Le fichier existe déjà sur le serveur (utilisez PUT <fichier> -f pour l'écraser)")
+		return true
+	} else if reponse.Code == p.CodeNameRefused {
+		log.Println("Envoi refusé par le serveur (nom invalide ou cible qui n'est pas un fichier)")
+		return true
+	} else if reponse.Code == p.CodeLocalError {
+		log.Println("Le serveur n'a pas pu préparer la réception du fichier")
+		return true
+	} else if reponse.Code != p.CodeStart {
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+		return true
+	}
+
+	// 150 : on envoie exactement la taille annoncée
+	if err := c.SendData(fichier, info.Size()); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi du fichier:", err)
+		}
+		log.Println("Erreur lors de l'envoi du fichier:", err)
+		return false
+	}
+
+	// Le serveur confirme une fois le fichier écrit et renommé à sa place
+	reponse, err = c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la confirmation PUT:", err)
+		}
+		return false
+	}
+
+	if reponse.Code == p.CodeOK {
+		log.Printf("Fichier '%s' envoyé (%d octets)\n", split[1], info.Size())
+	} else if reponse.Code == p.CodeFileExists {
+		log.Println("Le fichier a été créé sur le serveur pendant l'envoi (utilisez -f pour l'écraser)")
+	} else {
+		log.Println("Échec de l'écriture du fichier sur le serveur:", reponse.Code, reponse.Text)
+	}
+
+	return true
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// RenameClient demande au serveur de renommer ou de déplacer un fichier ou un dossier
+// split : [ "RENAME", "<source>", "<destination>" ], relatifs à la position tenue par le serveur ;
+// une destination qui est un dossier existant reçoit la source sous son nom actuel
+func RenameClient(c *p.Conn, split []string) bool {
+	if err := c.SendRequest(p.NewRequest("RENAME", split[1], split[2])); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande RENAME:", err)
+		}
+		return false
+	}
+
+	// Attendre la réponse du serveur
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse RENAME:", err)
+		}
+		return false
+	}
+
+	if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+	} else if reponse.Code == p.CodeFileUnknown {
+		log.Println("Source introuvable sur le serveur")
+	} else if reponse.Code == p.CodeFileExists {
+		log.Println("La destination existe déjà sur le serveur")
+	} else if reponse.Code == p.CodeNameRefused {
+		log.Println("Destination refusée par le serveur")
+	} else if reponse.Code == p.CodeLocalError {
+		log.Println("Erreur du serveur :", reponse.Text)
+	} else if reponse.Code == p.CodeOK {
+		log.Printf("'%s' : %s\n", split[1], reponse.Text)
+	} else {
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+	}
+
+	return true
+}
@@ -1,20 +1,17 @@
 package client
 
 import (
-	"bufio"
 	"errors"
 	"log"
 	"net"
-	"strings"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
 // RevealClient demande au serveur de révéler un fichier caché (port de contrôle).
-// split : [ "REVEAL", "<filename>", "<position>" ]
-func RevealClient(conn net.Conn, split []string, writer *bufio.Writer, reader *bufio.Reader) bool {
-	command := "REVEAL " + split[1] + " " + split[2]
-	if err := p.Send_message(conn, writer, command); err != nil {
+// split : [ "REVEAL", "<filename>" ], le nom étant relatif à la position tenue par le serveur
+func RevealClient(c *p.Conn, split []string) bool {
+	if err := c.SendRequest(p.NewRequest("REVEAL", split[1])); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de l'envoi de la commande REVEAL:", err)
@@ -23,7 +20,7 @@ func RevealClient(conn net.Conn, split []string, writer *bufio.Writer, reader *b
 	}
 
 	// Attendre la réponse du serveur
-	response, err := p.Receive_message(conn, reader)
+	reponse, err := c.ReceiveResponse()
 	if err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
@@ -31,14 +28,15 @@ func RevealClient(conn net.Conn, split []string, writer *bufio.Writer, reader *b
 		}
 		return false
 	}
-	response = strings.TrimSpace(response)
 
-	if response == "FileUnknown" {
+	if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+	} else if reponse.Code == p.CodeFileUnknown {
 		log.Println("Fichier introuvable (ou pas caché) sur le serveur")
-	} else if response == "OK" {
+	} else if reponse.Code == p.CodeOK {
 		log.Printf("Fichier '%s' révélé avec succès\n", split[1])
 	} else {
-		log.Println("Réponse inattendue du serveur:", response)
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
 	}
 
 	return true
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// RmdirClient demande au serveur de supprimer un dossier vide, ou avec tout son contenu
+// split : [ "RMDIR", "<dir>" ] ou [ "RMDIR", "<dir>", "-r" ], le nom étant relatif à la position tenue par le serveur
+func RmdirClient(c *p.Conn, split []string) bool {
+	if err := c.SendRequest(p.NewRequest("RMDIR", split[1:]...)); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande RMDIR:", err)
+		}
+		return false
+	}
+
+	// Attendre la réponse du serveur
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse RMDIR:", err)
+		}
+		return false
+	}
+
+	if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+	} else if reponse.Code == p.CodeFileUnknown {
+		log.Println("Dossier introuvable sur le serveur")
+	} else if reponse.Code == p.CodeNotEmpty {
+		log.Println("Le dossier n'est pas vide : utiliser RMDIR <dir> -r pour le supprimer avec son contenu")
+	} else if reponse.Code == p.CodeNameRefused || reponse.Code == p.CodeSyntaxError {
+		log.Println("Suppression refusée :", reponse.Text)
+	} else if reponse.Code == p.CodeLocalError {
+		log.Println("Erreur du serveur :", reponse.Text)
+	} else if reponse.Code == p.CodeOK {
+		log.Printf("Dossier '%s' supprimé avec succès\n", split[1])
+	} else {
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+	}
+
+	return true
+}
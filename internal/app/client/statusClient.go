@@ -0,0 +1,51 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"net"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// StatusClient demande l'état du serveur (commande disponible sur le port de contrôle) :
+// opérations en cours, sessions ouvertes par adresse IP et par utilisateur, et limites de connexions
+func StatusClient(c *p.Conn) bool {
+	if err := c.SendRequest(p.NewRequest("STATUS")); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande STATUS:", err)
+		}
+		return false
+	}
+
+	// Attendre la réponse du serveur
+	reponse, err := c.ReceiveResponse()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse STATUS:", err)
+		}
+		return false
+	}
+
+	switch reponse.Code {
+	case p.CodeStatus:
+		// "clé=valeur" séparés par des espaces, une limite à 0 signifiant pas de limite
+		champs, err := p.SplitArgs(reponse.Text)
+		if err != nil {
+			log.Println("État invalide reçu du serveur:", err)
+			return true
+		}
+		log.Println("\n=== État du serveur ===")
+		for _, champ := range champs {
+			log.Println(champ)
+		}
+		log.Println("=======================")
+	case p.CodePermissionDenied:
+		log.Println("Permission refusée par le serveur")
+	default:
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+	}
+	return true
+}
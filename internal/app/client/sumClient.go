@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// SumClient demande l'empreinte SHA-256 d'un fichier du serveur et l'affiche.
+// Si un fichier du même nom existe dans le dossier de travail, son empreinte est comparée.
+// split : [ "SUM", "<filename>" ], le nom étant relatif à la position tenue par le serveur
+func SumClient(c *p.Conn, split []string) bool {
+	if !Capacites.Has(p.FeatureChecksum) {
+		log.Println("SUM n'est pas supporté par le serveur (fonctionnalité checksum non négociée)")
+		return true
+	}
+	if err := c.SendRequest(p.NewRequest("SUM", split[1])); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la commande SUM:", err)
+		}
+		return false
+	}
+
+	// Attend la réponse du serveur (précédée de "110" pendant le calcul d'un gros fichier)
+	reponse, err := recevoirEmpreinte(c)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception de la réponse SUM:", err)
+		}
+		return false
+	}
+
+	// "213 <sha256>" : le texte de la réponse est l'empreinte
+	somme := reponse.Text
+	if reponse.Code != p.CodeChecksum {
+		if reponse.Code == p.CodePermissionDenied {
+			log.Println("Permission refusée par le serveur")
+		} else if reponse.Code == p.CodeFileUnknown {
+			log.Println("Fichier introuvable sur le serveur")
+		} else {
+			log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+		}
+		return true
+	}
+	log.Printf("sha256 de '%s' sur le serveur : %s\n", split[1], somme)
+
+	// Comparaison avec la copie locale éventuelle
+	var nomLocal = p.NormalizeName(filepath.Base(split[1]))
+	if _, err := os.Stat(nomLocal); err == nil {
+		locale, err := p.ChecksumFile(nomLocal)
+		if err != nil {
+			log.Println("Erreur lors du calcul de l'empreinte locale:", err)
+		} else if locale == somme {
+			log.Println("La copie locale est identique au fichier du serveur")
+		} else {
+			log.Printf("La copie locale est différente (sha256 local : %s)\n", locale)
+		}
+	}
+
+	return true
+}
+
+// recevoirEmpreinte attend la réponse "213 <sha256>" de SUM ou de la fin d'un GET, en ignorant les messages
+// "110" envoyés par le serveur tant que le calcul de l'empreinte n'est pas terminé.
+func recevoirEmpreinte(c *p.Conn) (p.Response, error) {
+	for {
+		reponse, err := c.ReceiveResponse()
+		if err != nil || reponse.Code != p.CodeInProgress {
+			return reponse, err
+		}
+		slog.Debug("Serveur : " + reponse.Text)
+	}
+}
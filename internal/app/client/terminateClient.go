@@ -1,20 +1,18 @@
 package client
 
 import (
-	"bufio"
 	"errors"
 	"io"
 	"log"
 	"net"
-	"strings"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
 // TerminateClient envoie la commande TERMINATE au serveur de contrôle et attend la progression
-// La boucle lit tous les messages jusqu'à ce que le serveur dit qu'il s'arrête
-func TerminateClient(conn net.Conn, writer *bufio.Writer, reader *bufio.Reader) bool {
-	if err := p.Send_message(conn, writer, "Terminate"); err != nil {
+// La boucle lit les messages d'avancement (110) jusqu'à ce que le serveur annonce son arrêt (221)
+func TerminateClient(c *p.Conn) bool {
+	if err := c.SendRequest(p.NewRequest("Terminate")); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de l'envoi de la commande TERMINATE:", err)
@@ -25,7 +23,7 @@ func TerminateClient(conn net.Conn, writer *bufio.Writer, reader *bufio.Reader)
 	log.Println("Commande TERMINATE envoyée, attente de la réponse du serveur...")
 
 	for {
-		rep, err := p.Receive_message(conn, reader)
+		reponse, err := c.ReceiveResponse()
 		if err != nil {
 			// La connexion peut être fermée après le message final
 			if err == io.EOF {
@@ -39,18 +37,20 @@ func TerminateClient(conn net.Conn, writer *bufio.Writer, reader *bufio.Reader)
 			return false
 		}
 
-		rep = strings.TrimSpace(rep)
-
-		// On affiche les différents messages d'avancement ou le message final
-		if rep == "Terminaison finie, le serveur s'éteint" {
-			log.Println(rep)
+		// On affiche les différents messages d'avancement (110) jusqu'à la réponse finale
+		switch reponse.Code {
+		case p.CodeInProgress:
+			log.Println(reponse.Text)
+		case p.CodeBye:
+			log.Println(reponse.Text)
 			log.Println("Le serveur s'est arrêté avec succès")
 			return true
-		} else if strings.Contains(rep, "opération") {
-			// message d'état intermédiaire
-			log.Println(rep)
-		} else {
-			log.Println(rep)
+		case p.CodePermissionDenied:
+			log.Println("Permission refusée : TERMINATE réservé aux administrateurs")
+			return true
+		default:
+			log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
+			return true
 		}
 	}
 }
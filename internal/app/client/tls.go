@@ -0,0 +1,45 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// NewTLSConfig prépare la configuration TLS du client.
+// - caFile : autorité de confiance pour vérifier le serveur (sinon, autorités du système) ;
+// - certFile/keyFile : certificat client, nécessaire si le port de contrôle exige le mTLS ;
+// - insecure : ne vérifie pas le certificat du serveur (tests uniquement).
+func NewTLSConfig(caFile string, certFile string, keyFile string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("lecture du certificat d'autorité: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("aucun certificat valide dans %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("-cert et -key doivent être fournis ensemble")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("chargement du certificat client: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -1,8 +1,8 @@
 package client
 
 import (
-	"bufio"
 	"errors"
+	"fmt"
 	"log"
 	"net"
 	"strings"
@@ -10,10 +10,18 @@ import (
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
-// treeClient demande l'arbre (liste) d'un dossier et l'affiche.
-// Similaire à ListClient mais affiche aussi le contexte ("vous êtes à la racine", etc.).
-func treeClient(conn net.Conn, split []string, writer *bufio.Writer, reader *bufio.Reader) bool {
-	if err := p.Send_message(conn, writer, "tree "+split[1]); err != nil {
+// treeClient demande l'arbre du dossier courant et l'affiche avec une indentation par niveau.
+// split : [ "TREE" ], suivi éventuellement de "-depth", "<N>" (nombre de niveaux) et de "-json"
+func treeClient(c *p.Conn, split []string, posActuelle string) bool {
+	args, enJSON, ok := optionJSON(split[1:])
+	if !ok {
+		return true
+	}
+	var req = p.NewRequest("tree", args...)
+	if enJSON {
+		req.Args = append(req.Args, p.OptionJSON)
+	}
+	if err := c.SendRequest(req); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de l'envoi de la commande TREE:", err)
@@ -22,7 +30,7 @@ func treeClient(conn net.Conn, split []string, writer *bufio.Writer, reader *buf
 	}
 
 	// Attend la réponse du serveur
-	var response, err = p.Receive_message(conn, reader)
+	reponse, err := c.ReceiveResponse()
 	if err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
@@ -30,51 +38,56 @@ func treeClient(conn net.Conn, split []string, writer *bufio.Writer, reader *buf
 		}
 		return false
 	}
-	response = strings.TrimSpace(response)
-
-	if response == "Start" {
-		// Le serveur va envoyer la liste ; on confirme par "OK"
-		if err := p.Send_message(conn, writer, "OK"); err != nil {
-			var netErr net.Error
-			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de l'envoi de 'OK':", err)
-			}
-			return false
-		}
 
-		data, err := p.Receive_message(conn, reader)
-		if err != nil {
-			var netErr net.Error
-			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de la lecture de la liste TREE:", err)
-			}
-			return false
-		}
-
-		var datas = strings.Split(data, "--")
-		if split[1] == "Docs" {
-			// racine
-			log.Println("vous êtes à la racine")
+	if reponse.Code == p.CodeStart {
+		// Le serveur envoie l'arbre au fil du parcours, chaque dossier avant son contenu
+		var nombre int
+		var ok bool
+		if enJSON {
+			_, ok = recevoirListe(c, "TREE", func(element string) error {
+				fmt.Println(element)
+				return nil
+			})
 		} else {
-			log.Println("vous êtes dans", split[1])
+			log.Println("\n=== Arborescence ===")
+			log.Println(posActuelle + "/")
+			nombre, ok = recevoirListe(c, "TREE", func(element string) error {
+				e, err := p.ParseEntry(element)
+				if err == nil {
+					afficherElementArbre(e)
+				}
+				return err
+			})
+			log.Println("Nombre d'éléments :", nombre)
+			log.Println("====================")
 		}
-		log.Println("\n=== Liste des fichiers disponibles ===")
-		for _, item := range datas {
-			if strings.TrimSpace(item) != "" {
-				log.Println(strings.TrimSpace(item))
-			}
+		if !ok {
+			return false
 		}
-		log.Println("=====================================")
+	} else if reponse.Code == p.CodePermissionDenied {
+		log.Println("Permission refusée par le serveur")
+		return true
+	} else {
+		log.Println("Réponse inattendue du serveur:", reponse.Code, reponse.Text)
 	}
 
-	// Fin de l'opération TREE : envoi de l'acquittement final "ok"
-	if err := p.Send_message(conn, writer, "ok"); err != nil {
-		var netErr net.Error
-		if errors.As(err, &netErr) && netErr.Timeout() {
-			log.Println("Timeout lors de l'envoi de 'ok' final TREE:", err)
-		}
-		return false
+	return true
+}
+
+// afficherElementArbre affiche un élément de TREE dès sa réception, indenté selon la profondeur de son chemin
+// ("docs/", "docs/salut.txt") : seul le dernier composant est affiché.
+func afficherElementArbre(e p.Entry) {
+	var chemin = strings.TrimSuffix(e.Name, "/")
+	var nom = chemin
+	var profondeur = strings.Count(chemin, "/")
+	if i := strings.LastIndex(chemin, "/"); i >= 0 {
+		nom = chemin[i+1:]
 	}
 
-	return true
+	var indentation = strings.Repeat("│   ", profondeur) + "├── "
+	if strings.HasSuffix(e.Name, "/") {
+		log.Println(indentation + nom + "/")
+	} else {
+		log.Printf("%s%s (%d)\n", indentation, nom, e.Size)
+	}
 }
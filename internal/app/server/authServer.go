@@ -0,0 +1,233 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// Politique de blocage : après tentativesMax échecs consécutifs, le compte est bloqué pendant dureeBlocage.
+const (
+	tentativesMax = 3
+	dureeBlocage  = 5 * time.Minute
+)
+
+// RoleAdmin : rôle requis pour se connecter au port de contrôle.
+const RoleAdmin = "admin"
+
+// ErrIdentifiants : nom d'utilisateur inconnu ou mot de passe incorrect.
+var ErrIdentifiants = errors.New("identifiants invalides")
+
+// ErrCompteBloque : trop d'échecs récents pour ce compte.
+var ErrCompteBloque = errors.New("compte temporairement bloqué")
+
+// User : compte défini dans le fichier des utilisateurs.
+type User struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"` // empreinte bcrypt du mot de passe
+	Root string `json:"root"` // dossier servi à cet utilisateur (vide = racine du serveur)
+	Role string `json:"role"` // "admin" ou "user"
+
+	// Permissions générales (absentes = permissions par défaut du rôle), et règles par sous-dossier
+	Permissions []Permission   `json:"permissions"`
+	Rules       []RegleDossier `json:"rules"`
+}
+
+// echecsLogin : échecs consécutifs d'un compte et fin de son éventuel blocage.
+type echecsLogin struct {
+	nombre       int
+	bloqueJusqua time.Time
+}
+
+// userStore : comptes chargés au démarrage et suivi des échecs de connexion (comptes existants seulement).
+type userStore struct {
+	users  map[string]User
+	echecs map[string]*echecsLogin
+	mutex  sync.Mutex
+}
+
+// comptes : nil si aucun fichier d'utilisateurs n'est configuré (accès sans authentification).
+var comptes *userStore
+
+// empreinteFactice sert à comparer un mot de passe même pour un utilisateur inconnu,
+// pour que le temps de réponse ne révèle pas quels comptes existent.
+var empreinteFactice, _ = bcrypt.GenerateFromPassword([]byte("factice"), bcrypt.DefaultCost)
+
+// loadUsers lit le fichier JSON des utilisateurs. Une racine vide est remplacée par defaultRoot.
+func loadUsers(path string, defaultRoot string) (*userStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("lecture du fichier des utilisateurs: %w", err)
+	}
+	var liste []User
+	if err := json.Unmarshal(data, &liste); err != nil {
+		return nil, fmt.Errorf("fichier des utilisateurs %s invalide: %w", path, err)
+	}
+
+	store := &userStore{users: make(map[string]User), echecs: make(map[string]*echecsLogin)}
+	for _, user := range liste {
+		if user.Name == "" || user.Hash == "" {
+			return nil, fmt.Errorf("fichier des utilisateurs %s : nom et hash obligatoires", path)
+		}
+		if user.Root == "" {
+			user.Root = defaultRoot
+		}
+		if info, err := os.Stat(user.Root); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("dossier de l'utilisateur %s invalide : %s", user.Name, user.Root)
+		}
+		store.users[user.Name] = user
+	}
+	return store, nil
+}
+
+// authenticate vérifie le mot de passe de name et applique la politique de blocage.
+func (s *userStore) authenticate(name string, password string) (User, error) {
+	s.mutex.Lock()
+	echecs := s.echecs[name]
+	if echecs != nil && time.Now().Before(echecs.bloqueJusqua) {
+		s.mutex.Unlock()
+		return User{}, ErrCompteBloque
+	}
+	user, existe := s.users[name]
+	s.mutex.Unlock()
+
+	// bcrypt est lent : la comparaison se fait hors du verrou
+	var hash = empreinteFactice
+	if existe {
+		hash = []byte(user.Hash)
+	}
+	errCompare := bcrypt.CompareHashAndPassword(hash, []byte(password))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if existe && errCompare == nil {
+		delete(s.echecs, name)
+		return user, nil
+	}
+	if !existe {
+		// Seuls les comptes existants sont suivis : un client ne peut pas remplir s.echecs de noms inventés
+		return User{}, ErrIdentifiants
+	}
+
+	echecs = s.echecs[name]
+	if echecs == nil {
+		echecs = &echecsLogin{}
+		s.echecs[name] = echecs
+	}
+	echecs.nombre++
+	if echecs.nombre >= tentativesMax {
+		echecs.nombre = 0
+		echecs.bloqueJusqua = time.Now().Add(dureeBlocage)
+		log.Println("Compte bloqué après", tentativesMax, "échecs :", name)
+	}
+	return User{}, ErrIdentifiants
+}
+
+// HashPassword retourne l'empreinte bcrypt d'un mot de passe, à placer dans le fichier des utilisateurs.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// etatLogin : avancement de l'authentification d'une session.
+type etatLogin struct {
+	nomDemande string // nom reçu par USER, en attente de PASS
+	user       *User  // utilisateur authentifié, nil tant que le login n'a pas réussi
+}
+
+// authentifie indique si la session peut exécuter des commandes.
+func (e *etatLogin) authentifie() bool {
+	return comptes == nil || e.user != nil
+}
+
+// LoginServer traite les commandes USER et PASS.
+// - "USER <nom>" : répond 331 (mot de passe attendu) ;
+// - "PASS <mot de passe>" : répond "230 <position>" et place la session dans le dossier de l'utilisateur,
+// 531 si les identifiants sont invalides, 430 si le compte est bloqué, ou 532 sur le port de contrôle sans rôle admin.
+// Si l'utilisateur a déjà cfg.MaxSessionsPerUser sessions ouvertes (ignoré sur le port de contrôle),
+// répond 429 et ferme la connexion. Sur le port de contrôle, le login d'un admin réserve le port (voir prendreControle) :
+// s'il est occupé, répond 420 et ferme la connexion.
+func LoginServer(c *p.Conn, fsys *vfs, req p.Request, etat *etatLogin, controle bool, cfg *Config) bool {
+	var code p.Code
+	var texte string
+	var fermer bool
+	var maxParUtilisateur = cfg.MaxSessionsPerUser
+	if controle {
+		// le port de contrôle n'admet qu'une session : sa connexion est comptée mais pas limitée
+		maxParUtilisateur = 0
+	}
+
+	switch {
+	case strings.EqualFold(req.Command, "USER"):
+		etat.nomDemande = req.Args[0]
+		code, texte = p.CodePassRequired, "Mot de passe requis"
+
+	case etat.nomDemande == "":
+		code, texte = p.CodeLoginFailed, "Identifiants invalides"
+
+	default:
+		var nom = etat.nomDemande
+		etat.nomDemande = ""
+		user, err := comptes.authenticate(nom, req.Args[0])
+		if errors.Is(err, ErrCompteBloque) {
+			log.Println("Tentative de connexion sur un compte bloqué :", nom, c.RemoteAddr().String())
+			code, texte = p.CodeLocked, "Compte temporairement bloqué"
+		} else if err != nil {
+			log.Println("Échec d'authentification de", nom, "depuis", c.RemoteAddr().String())
+			code, texte = p.CodeLoginFailed, "Identifiants invalides"
+		} else if controle && user.Role != RoleAdmin {
+			log.Println("Connexion au port de contrôle refusée, rôle admin requis :", user.Name)
+			code, texte = p.CodeAdminRequired, "Rôle admin requis"
+		} else if err := fsys.changerRacine(user.Root); err != nil {
+			log.Println("Dossier de l'utilisateur inaccessible :", user.Name, err)
+			code, texte = p.CodeLoginFailed, "Identifiants invalides"
+		} else if ok, motif := connexions.connecter(user.Name, maxParUtilisateur); !ok {
+			log.Println("Connexion refusée pour", c.RemoteAddr().String(), ":", motif)
+			code, texte, fermer = p.CodeTooManyConns, motif, true
+		} else {
+			etat.user = &user
+			log.Println("Utilisateur authentifié :", user.Name, "depuis", c.RemoteAddr().String())
+			code, texte = p.CodeLoggedIn, fsys.position()
+		}
+	}
+
+	// Le port de contrôle n'est réservé qu'une fois l'admin authentifié
+	if controle && etat.user != nil {
+		if ok, occupe := prendreControle(c, cfg.ControlTakeover); !ok {
+			connexions.deconnecter(etat.user.Name)
+			etat.user = nil
+			code, texte, fermer = p.CodeControlBusy, occupe, true
+		}
+	}
+
+	if err := c.SendResponse(code, texte); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la réponse de login:", err)
+		}
+		return false
+	}
+	return !fermer
+}
+
+// commandeHorsLogin indique si la commande est acceptée avant l'authentification.
+func commandeHorsLogin(req p.Request) bool {
+	switch strings.ToUpper(req.Command) {
+	case "START", "END", "USER", "PASS":
+		return true
+	}
+	return false
+}
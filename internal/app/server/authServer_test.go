@@ -0,0 +1,40 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticateEchecs(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var store = &userStore{
+		users:  map[string]User{"bob": {Name: "bob", Hash: string(hash)}},
+		echecs: make(map[string]*echecsLogin),
+	}
+
+	// Les noms inconnus sont refusés sans être suivis
+	for i := 0; i < 3*tentativesMax; i++ {
+		if _, err := store.authenticate(fmt.Sprintf("inconnu%d", i), "secret"); !errors.Is(err, ErrIdentifiants) {
+			t.Fatalf("authenticate(inconnu%d) = %v ; attendu ErrIdentifiants", i, err)
+		}
+	}
+	if len(store.echecs) != 0 {
+		t.Fatalf("%d noms inconnus suivis ; attendu aucun", len(store.echecs))
+	}
+
+	// Un compte existant est bloqué après tentativesMax échecs, même avec le bon mot de passe
+	for i := 0; i < tentativesMax; i++ {
+		if _, err := store.authenticate("bob", "faux"); !errors.Is(err, ErrIdentifiants) {
+			t.Fatalf("échec %d : %v ; attendu ErrIdentifiants", i+1, err)
+		}
+	}
+	if _, err := store.authenticate("bob", "secret"); !errors.Is(err, ErrCompteBloque) {
+		t.Fatalf("authenticate(bob) après %d échecs = %v ; attendu ErrCompteBloque", tentativesMax, err)
+	}
+}
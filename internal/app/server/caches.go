@@ -0,0 +1,227 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// FichierCaches : nom du registre des éléments cachés, placé à la racine servie (Config.Root).
+// Il commence par '.' : comme tout élément dont le nom commence par un point, il n'est jamais servi aux clients.
+const FichierCaches = ".hidden.json"
+
+// registreCaches : éléments cachés par HIDE. Les fichiers ne sont ni renommés ni modifiés :
+// seul le registre est mis à jour, et enregistré sur le disque à chaque changement.
+// Un dossier caché cache aussi tout son contenu.
+// Indépendamment du registre, un élément dont le nom commence par '.' est toujours caché (voir estCache) :
+// c'est ainsi que les anciennes versions du serveur cachaient un fichier, en le renommant ".<nom>" ;
+// REVEAL les révèle en retirant ce point, et HIDDEN les liste avec le registre (voir cachesParPoint).
+// Le registre est unique : ses chemins sont relatifs à la racine servie, quelle que soit la racine de la session
+// qui a caché l'élément (voir vfs.dansRegistre), pour qu'un élément caché le soit pour tous les utilisateurs.
+type registreCaches struct {
+	mu      sync.Mutex
+	racine  string          // chemin réel de la racine servie
+	fichier string          // chemin réel du registre
+	chemins map[string]bool // chemins relatifs à la racine servie, normalisés en NFC et séparés par '/'
+}
+
+// contenuCaches : format du registre sur le disque.
+type contenuCaches struct {
+	Hidden []string `json:"hidden"`
+}
+
+// caches : registre des éléments cachés, chargé par chargerCaches au démarrage du serveur.
+var caches *registreCaches
+
+// chargerCaches charge le registre des éléments cachés de la racine servie racine.
+// Un registre illisible est loggé et remplacé par un registre vide au prochain enregistrement.
+func chargerCaches(racine string) error {
+	fsys, err := newVFS(racine)
+	if err != nil {
+		return err
+	}
+
+	var r = &registreCaches{racine: fsys.root, fichier: filepath.Join(fsys.root, FichierCaches), chemins: make(map[string]bool)}
+	data, err := os.ReadFile(r.fichier)
+	if err == nil {
+		var contenu contenuCaches
+		if err = json.Unmarshal(data, &contenu); err == nil {
+			for _, chemin := range contenu.Hidden {
+				r.chemins[chemin] = true
+			}
+		}
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Println("Registre des fichiers cachés illisible:", r.fichier, err)
+	}
+	caches = r
+	return nil
+}
+
+// contient indique si rel, ou un de ses dossiers parents, est caché.
+func (r *registreCaches) contient(rel string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for chemin := rel; chemin != ""; {
+		if r.chemins[chemin] {
+			return true
+		}
+		i := strings.LastIndex(chemin, "/")
+		if i < 0 {
+			break
+		}
+		chemin = chemin[:i]
+	}
+	return false
+}
+
+// cacher ajoute rel au registre.
+func (r *registreCaches) cacher(rel string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chemins[rel] = true
+	return r.enregistrer()
+}
+
+// reveler retire rel du registre ; false s'il n'y figurait pas.
+func (r *registreCaches) reveler(rel string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.chemins[rel] {
+		return false, nil
+	}
+	delete(r.chemins, rel)
+	return true, r.enregistrer()
+}
+
+// deplacer suit le renommage de ancien en nouveau (RENAME) : les éléments cachés qu'il contient le restent.
+func (r *registreCaches) deplacer(ancien, nouveau string) error {
+	return r.modifier(ancien, func(suite string) {
+		r.chemins[nouveau+suite] = true
+	})
+}
+
+// oublier retire du registre rel et son contenu, supprimés (DELETE, RMDIR) :
+// un élément créé plus tard sous le même nom ne doit pas être caché.
+func (r *registreCaches) oublier(rel string) error {
+	return r.modifier(rel, func(string) {})
+}
+
+// modifier retire du registre dossier et son contenu, appelle remplacer avec la fin de chaque chemin retiré
+// ("" pour dossier lui-même, "/..." pour son contenu), puis enregistre le registre s'il a changé.
+func (r *registreCaches) modifier(dossier string, remplacer func(suite string)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var suites []string
+	for chemin := range r.chemins {
+		if suite, ok := sousChemin(chemin, dossier); ok {
+			delete(r.chemins, chemin)
+			suites = append(suites, suite)
+		}
+	}
+	if len(suites) == 0 {
+		return nil
+	}
+	for _, suite := range suites {
+		remplacer(suite)
+	}
+	return r.enregistrer()
+}
+
+// surDisque retourne le chemin réel de chemin, relatif à la racine servie.
+func (r *registreCaches) surDisque(chemin string) string {
+	return (&vfs{root: r.racine}).surDisque(chemin)
+}
+
+// cachesParPoint retourne les chemins, relatifs à la racine servie, des éléments cachés par leur nom
+// (commençant par '.'), dans l'ordre du parcours ; le contenu d'un dossier caché n'est pas détaillé,
+// et le registre lui-même (FichierCaches et ses fichiers temporaires) n'en fait pas partie.
+func (r *registreCaches) cachesParPoint() []string {
+	var chemins []string
+	var racine = &vfs{root: r.racine}
+	err := filepath.WalkDir(r.racine, func(reel string, entree fs.DirEntry, err error) error {
+		if err != nil {
+			log.Println("Lecture impossible pendant la recherche des fichiers cachés:", reel, err)
+			return nil
+		}
+		if reel == r.racine || !strings.HasPrefix(entree.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(r.racine, reel)
+		if err != nil {
+			return nil
+		}
+		rel = p.NormalizeName(filepath.ToSlash(rel))
+		if !racine.interne(rel) {
+			chemins = append(chemins, rel)
+		}
+		if entree.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Recherche des fichiers cachés interrompue:", err)
+	}
+	return chemins
+}
+
+// liste retourne les chemins cachés, triés.
+func (r *registreCaches) liste() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var chemins = make([]string, 0, len(r.chemins))
+	for chemin := range r.chemins {
+		chemins = append(chemins, chemin)
+	}
+	sort.Strings(chemins)
+	return chemins
+}
+
+// enregistrer écrit le registre dans un fichier temporaire puis le renomme à sa place (appelé sous r.mu).
+func (r *registreCaches) enregistrer() error {
+	var contenu = contenuCaches{Hidden: make([]string, 0, len(r.chemins))}
+	for chemin := range r.chemins {
+		contenu.Hidden = append(contenu.Hidden, chemin)
+	}
+	sort.Strings(contenu.Hidden)
+	data, err := json.MarshalIndent(contenu, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(r.fichier), FichierCaches+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if errClose := temp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), r.fichier)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+// sousChemin indique si chemin est dossier ou se trouve dans dossier, et retourne la fin du chemin ("" ou "/...").
+func sousChemin(chemin, dossier string) (string, bool) {
+	if chemin == dossier {
+		return "", true
+	}
+	if strings.HasPrefix(chemin, dossier+"/") {
+		return chemin[len(dossier):], true
+	}
+	return "", false
+}
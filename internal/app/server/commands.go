@@ -0,0 +1,348 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// Port : listener(s) sur lesquels une commande est disponible.
+type Port int
+
+const (
+	PortNormal   Port = 1 << iota // clients normaux
+	PortControle                  // port de contrôle
+	TousLesPorts = PortNormal | PortControle
+)
+
+// session : état d'une connexion cliente, transmis à chaque commande.
+type session struct {
+	conn  *p.Conn
+	fsys  *vfs      // dossier courant, confiné à la racine servie
+	login etatLogin // authentification (sans effet si aucun compte n'est configuré)
+	port  Port      // listener qui a accepté la connexion
+	cfg   *Config   // configuration du serveur
+
+	// Version et fonctionnalités négociées par "start" (vides tant que la session n'est pas ouverte)
+	caps p.Capabilities
+
+	// Fin de la session imposée par Config.MaxSessionDuration, zéro si sa durée n'est pas limitée
+	fin time.Time
+
+	// État vu par l'arrêt du serveur : une session occupée termine sa commande avant d'être déconnectée
+	etat    sync.Mutex
+	occupee bool
+}
+
+// Command : commande du protocole. Les deux listeners partagent le même registre.
+type Command interface {
+	Name() string               // mot-clé envoyé par le client ("List", "GET"...)
+	Arity() (min, max int)      // nombre d'arguments accepté, max < 0 pour illimité
+	Ports() Port                // ports sur lesquels la commande est disponible
+	Permission() Permission     // permission requise, "" pour une commande libre
+	Targets() int               // nombre de premiers arguments désignant un chemin, vérifiés par les permissions
+	Options() map[string]int    // options acceptées ("-json"...) et nombre de valeurs qui les suivent, qui ne sont pas des chemins
+	Subtree(req p.Request) bool // true si req agit sur toute l'arborescence de ses cibles (RMDIR -r, RENAME)
+	Feature() string            // fonctionnalité négociée requise (proto.Feature...), "" si aucune
+	Help() string               // ligne affichée par HELP, "" pour une commande interne au protocole
+	// Run exécute la requête reçue ; false ferme la connexion (erreur réseau ou fin de session).
+	Run(s *session, req p.Request) bool
+}
+
+// commande : implémentation de Command à partir d'une fonction.
+type commande struct {
+	nom        string
+	min, max   int
+	ports      Port
+	permission Permission
+	cibles     int                      // arguments désignant un chemin, 1 si non précisé (RENAME : source et destination)
+	sousArbre  func(req p.Request) bool // nil : la commande n'agit que sur ses cibles elles-mêmes
+	options    map[string]int
+	feature    string
+	aide       string
+	operation  bool // comptée dans les opérations en cours, attendues par TERMINATE
+	executer   func(s *session, req p.Request) bool
+}
+
+func (c *commande) Name() string           { return c.nom }
+func (c *commande) Arity() (int, int)      { return c.min, c.max }
+func (c *commande) Ports() Port            { return c.ports }
+func (c *commande) Permission() Permission { return c.permission }
+func (c *commande) Feature() string        { return c.feature }
+func (c *commande) Targets() int           { return max(c.cibles, 1) }
+func (c *commande) Help() string           { return c.aide }
+
+func (c *commande) Options() map[string]int { return c.options }
+
+func (c *commande) Subtree(req p.Request) bool { return c.sousArbre != nil && c.sousArbre(req) }
+
+func (c *commande) Run(s *session, req p.Request) bool {
+	if !c.operation {
+		return c.executer(s, req)
+	}
+	nbOp := incrementerOperations()
+	log.Println("Commande", c.nom, "reçue, opérations en cours:", nbOp)
+	if !c.executer(s, req) {
+		decrementerOperations()
+		return false
+	}
+	nbOp = decrementerOperations()
+	log.Println("Commande", c.nom, "terminée, opérations restantes:", nbOp)
+	return true
+}
+
+// registre : commandes connues, dans l'ordre d'enregistrement (celui de HELP).
+// Les noms sont indexés en majuscules : une commande est reconnue quelle que soit sa casse
+// ("LIST", ou "List" envoyé par les anciens clients).
+type registre struct {
+	ordre []Command
+	noms  map[string]Command
+}
+
+// commandes : registre partagé par le serveur normal et le serveur de contrôle.
+var commandes = &registre{noms: make(map[string]Command)}
+
+// Register ajoute une commande au registre ; un nom déjà enregistré est une erreur de programmation.
+func (r *registre) Register(c Command) {
+	var cle = strings.ToUpper(c.Name())
+	if _, existe := r.noms[cle]; existe {
+		panic("commande enregistrée deux fois : " + c.Name())
+	}
+	r.noms[cle] = c
+	r.ordre = append(r.ordre, c)
+}
+
+// disponible indique si c peut être utilisée sur port avec les fonctionnalités négociées caps.
+func disponible(c Command, port Port, caps p.Capabilities) bool {
+	return c.Ports()&port != 0 && (c.Feature() == "" || caps.Has(c.Feature()))
+}
+
+// Lookup retourne la commande demandée par req (nom reconnu sans tenir compte de la casse)
+// si elle est disponible et accepte le nombre d'arguments reçu.
+// Sinon, la commande est nil et code, texte donnent la réponse d'erreur à envoyer au client :
+// 500 si elle est inconnue ou indisponible sur port, 504 si sa fonctionnalité n'est pas négociée,
+// 501 si le nombre d'arguments est invalide.
+func (r *registre) Lookup(req p.Request, port Port, caps p.Capabilities) (c Command, code p.Code, texte string) {
+	c, existe := r.noms[strings.ToUpper(req.Command)]
+	if !existe || c.Ports()&port == 0 {
+		return nil, p.CodeUnknownCommand, "Commande inconnue : " + req.Command + ". Veuillez entrer HELP pour avoir la liste de commande."
+	}
+	if !disponible(c, port, caps) {
+		return nil, p.CodeOptionRefused, "Fonctionnalité " + c.Feature() + " non négociée pour " + req.Command
+	}
+	min, max := c.Arity()
+	nbArgs := len(req.Args)
+	if nbArgs < min || (max >= 0 && nbArgs > max) {
+		texte = "Nombre d'arguments invalide pour " + req.Command
+		if c.Help() != "" {
+			texte += ", usage : " + c.Help()
+		}
+		return nil, p.CodeSyntaxError, texte
+	}
+	return c, 0, ""
+}
+
+// Help construit le message d'aide des commandes disponibles sur port avec les fonctionnalités caps.
+func (r *registre) Help(port Port, caps p.Capabilities, debug bool) string {
+	var lignes []string
+	for _, c := range r.ordre {
+		if disponible(c, port, caps) && c.Help() != "" {
+			lignes = append(lignes, c.Help())
+		}
+	}
+	if debug {
+		lignes = append(lignes, "MESSAGES")
+	}
+	return "Commandes disponibles : " + strings.Join(lignes, ", ")
+}
+
+func init() {
+	// Protocole : ouverture, authentification et fin de session
+	commandes.Register(&commande{nom: "start", max: -1, ports: TousLesPorts, executer: startCommande})
+	commandes.Register(&commande{nom: "USER", min: 1, max: 1, ports: TousLesPorts, executer: loginCommande})
+	commandes.Register(&commande{nom: "PASS", min: 1, max: 1, ports: TousLesPorts, executer: loginCommande})
+
+	// Fichiers
+	commandes.Register(&commande{nom: "List", max: 2, ports: TousLesPorts, permission: PermRead, aide: "LIST [dir] [-json]", operation: true,
+		options: map[string]int{p.OptionJSON: 0},
+		executer: func(s *session, req p.Request) bool {
+			return ListServer(s.conn, s.fsys, req, s.caps, s.port == PortControle)
+		}})
+	commandes.Register(&commande{nom: "MLSD", max: 1, ports: TousLesPorts, permission: PermRead, feature: p.FeatureMLSD, aide: "MLSD [dir]", operation: true,
+		executer: func(s *session, req p.Request) bool {
+			return MlsdServer(s.conn, s.fsys, req, s.port == PortControle)
+		}})
+	commandes.Register(&commande{nom: "GET", min: 1, max: 2, ports: PortNormal, permission: PermRead, feature: p.FeatureBinary, aide: "GET <filename> [offset]", operation: true,
+		executer: func(s *session, req p.Request) bool {
+			return Getserver(s.conn, s.fsys, req, s.caps)
+		}})
+	commandes.Register(&commande{nom: "PUT", min: 2, max: 3, ports: PortNormal, permission: PermWrite, feature: p.FeatureBinary, aide: "PUT <filename> <size> [-f]", operation: true,
+		executer: func(s *session, req p.Request) bool {
+			return PutServer(s.conn, s.fsys, req)
+		}})
+	commandes.Register(&commande{nom: "SUM", min: 1, max: 1, ports: PortNormal, permission: PermRead, feature: p.FeatureChecksum, aide: "SUM <filename>", operation: true,
+		executer: func(s *session, req p.Request) bool {
+			return SumServer(s.conn, s.fsys, req)
+		}})
+	commandes.Register(&commande{nom: "DELETE", min: 1, max: 1, ports: TousLesPorts, permission: PermWrite, aide: "DELETE <filename>", operation: true,
+		executer: func(s *session, req p.Request) bool {
+			return DeleteServer(s.conn, s.fsys, req)
+		}})
+	commandes.Register(&commande{nom: "RENAME", min: 2, max: 2, ports: TousLesPorts, permission: PermWrite, cibles: 2, aide: "RENAME <source> <destination>", operation: true,
+		sousArbre: func(p.Request) bool { return true },
+		executer: func(s *session, req p.Request) bool {
+			return RenameServer(s.conn, s.fsys, req)
+		}})
+	commandes.Register(&commande{nom: "MKDIR", min: 1, max: 1, ports: TousLesPorts, permission: PermWrite, aide: "MKDIR <dir>", operation: true,
+		executer: func(s *session, req p.Request) bool {
+			return MkdirServer(s.conn, s.fsys, req)
+		}})
+	commandes.Register(&commande{nom: "RMDIR", min: 1, max: 2, ports: TousLesPorts, permission: PermWrite, aide: "RMDIR <dir> [-r]", operation: true,
+		options: map[string]int{OptionRecursive: 0},
+		sousArbre: func(req p.Request) bool {
+			_, recursif := p.CutOption(req.Args, OptionRecursive)
+			return recursif
+		},
+		executer: func(s *session, req p.Request) bool {
+			return RmdirServer(s.conn, s.fsys, req)
+		}})
+	commandes.Register(&commande{nom: "HIDE", min: 1, max: 1, ports: PortControle, permission: PermHide, aide: "HIDE <filename>", operation: true,
+		executer: func(s *session, req p.Request) bool {
+			return HIDE(s.conn, s.fsys, req)
+		}})
+	commandes.Register(&commande{nom: "REVEAL", min: 1, max: 1, ports: PortControle, permission: PermHide, aide: "REVEAL <filename>", operation: true,
+		executer: func(s *session, req p.Request) bool {
+			return REVEAL(s.conn, s.fsys, req)
+		}})
+
+	commandes.Register(&commande{nom: "HIDDEN", ports: PortControle, permission: PermHide, aide: "HIDDEN", operation: true,
+		executer: func(s *session, req p.Request) bool {
+			return HiddenServer(s.conn, s.fsys)
+		}})
+
+	// Navigation
+	commandes.Register(&commande{nom: "GOTO", min: 1, max: 1, ports: TousLesPorts, permission: PermRead, aide: "GOTO <target>",
+		executer: func(s *session, req p.Request) bool {
+			return GOTO(req, s.fsys, s.conn)
+		}})
+	commandes.Register(&commande{nom: "tree", max: 3, ports: TousLesPorts, permission: PermRead, aide: "TREE [-depth N] [-json]",
+		options: map[string]int{OptionProfondeur: 1, p.OptionJSON: 0},
+		executer: func(s *session, req p.Request) bool {
+			return tree(s.conn, s.fsys, &s.login, req, s.caps, s.port == PortControle, s.cfg.TreeMaxEntries)
+		}})
+
+	// Session
+	commandes.Register(&commande{nom: "NOOP", ports: TousLesPorts, feature: p.FeatureNoop, executer: noopCommande})
+	commandes.Register(&commande{nom: "Help", max: 1, ports: TousLesPorts, aide: "HELP", executer: helpCommande})
+	commandes.Register(&commande{nom: "Unknown", ports: TousLesPorts, executer: unknownCommande})
+	commandes.Register(&commande{nom: "end", ports: TousLesPorts, aide: "END", executer: endCommande})
+	commandes.Register(&commande{nom: "STATUS", ports: PortControle, permission: PermAdmin, aide: "STATUS",
+		executer: func(s *session, req p.Request) bool {
+			return StatusServer(s.conn, s.cfg)
+		}})
+	commandes.Register(&commande{nom: "Terminate", ports: PortControle, permission: PermAdmin, aide: "TERMINATE", executer: terminateCommande})
+}
+
+// envoyerReponse envoie la réponse "<code> <texte>" ; false en cas d'erreur réseau.
+func envoyerReponse(s *session, code p.Code, texte string) bool {
+	if err := s.conn.SendResponse(code, texte); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Printf("Timeout lors de l'envoi de la réponse %d: %v\n", code, err)
+		}
+		return false
+	}
+	return true
+}
+
+// repondre envoie la réponse "<code> <texte>" d'une commande ; false en cas d'erreur réseau.
+func repondre(c *p.Conn, code p.Code, texte string, commande string) bool {
+	if err := c.SendResponse(code, texte); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la réponse", commande, code, ":", err)
+		}
+		return false
+	}
+	return true
+}
+
+// startCommande : "start <version> <fonctionnalités>" ouvre la session avec les capacités communes au client
+// et au serveur. Répond 200, ou 330 si un login USER/PASS est attendu ; 505 et fermeture de la connexion
+// si aucune version ou fonctionnalité obligatoire n'est commune (ou si le client n'annonce rien).
+func startCommande(s *session, req p.Request) bool {
+	client, _, err := p.ParseCapabilities(strings.Join(req.Args, " "))
+	if err == nil {
+		s.caps, err = p.Negotiate(p.Local(), client)
+	}
+	if err != nil {
+		log.Println("Négociation refusée avec", s.conn.RemoteAddr().String(), ":", err)
+		envoyerReponse(s, p.CodeVersionRefused, "Protocole incompatible ("+err.Error()+"), serveur en "+p.Local().String())
+		return false
+	}
+	log.Println("Session ouverte avec", s.conn.RemoteAddr().String(), ", protocole négocié :", s.caps.String())
+
+	if comptes != nil {
+		return envoyerReponse(s, p.CodeAuthRequired, "Authentification requise")
+	}
+	// Sans comptes, la session de contrôle est ouverte dès "start" : le port est réservé maintenant
+	if s.port == PortControle {
+		if ok, occupe := prendreControle(s.conn, s.cfg.ControlTakeover); !ok {
+			envoyerReponse(s, p.CodeControlBusy, occupe)
+			return false
+		}
+	}
+	return envoyerReponse(s, p.CodeOK, "ok")
+}
+
+// loginCommande : USER <nom> / PASS <mot de passe>, si des comptes sont configurés et la session pas encore authentifiée.
+// Un mot de passe contenant des espaces est envoyé entre guillemets par le client.
+func loginCommande(s *session, req p.Request) bool {
+	if comptes == nil || s.login.user != nil {
+		log.Println("Message inattendu du client:", req.Command)
+		return envoyerReponse(s, p.CodeBadSequence, "Authentification non attendue : session déjà ouverte")
+	}
+	return LoginServer(s.conn, s.fsys, req, &s.login, s.port == PortControle, s.cfg)
+}
+
+// helpCommande : liste générée depuis le registre ; "Help true" ajoute MESSAGES (client en mode debug).
+func helpCommande(s *session, req p.Request) bool {
+	var debug = len(req.Args) == 1 && req.Args[0] == "true"
+	return envoyerReponse(s, p.CodeHelp, commandes.Help(s.port, s.caps, debug))
+}
+
+// noopCommande : maintien de la session par un client interactif ; la session est active, son délai d'inactivité repart.
+func noopCommande(s *session, req p.Request) bool {
+	return envoyerReponse(s, p.CodeOK, "ok")
+}
+
+// unknownCommande : le client n'a pas reconnu la commande saisie, on le renvoie vers HELP.
+func unknownCommande(s *session, req p.Request) bool {
+	log.Println("Commande inconnue. Veuillez entrer HELP pour avoir la liste de commande.")
+	return envoyerReponse(s, p.CodeUnknownCommand, "Commande inconnue. Veuillez entrer HELP pour avoir la liste de commande.")
+}
+
+// endCommande : fin de la session cliente.
+func endCommande(s *session, req p.Request) bool {
+	envoyerReponse(s, p.CodeBye, "ok")
+	return false
+}
+
+// terminateCommande : éteint le serveur et déconnecte les autres clients une fois leurs opérations terminées.
+// Le client de contrôle reçoit l'avancement de l'arrêt, puis sa session est fermée.
+func terminateCommande(s *session, req p.Request) bool {
+	log.Println("Commande TERMINATE reçue")
+	a := arretCourant()
+	if !a.demander(s) {
+		envoyerReponse(s, p.CodeShuttingDown, messageArret)
+		return false
+	}
+	// Attendre la fin des autres sessions (drainer envoie les messages d'avancement et la confirmation 221)
+	<-a.fini
+	return false
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config regroupe les paramètres de lancement du serveur.
+// Elle peut être lue depuis un fichier JSON ; les options de la ligne de commande restent prioritaires.
+type Config struct {
+	Port        string `json:"port"`        // port des clients normaux
+	ControlPort string `json:"controlPort"` // port de contrôle
+	Root        string `json:"root"`        // dossier servi aux clients
+
+	// Le port de contrôle n'admet qu'une session : une nouvelle connexion est refusée ("refuse", défaut)
+	// ou remplace la session en place ("replace"), voir ReprendreRefuser
+	ControlTakeover string `json:"controlTakeover"`
+
+	TreeMaxEntries int `json:"treeMax"` // nombre maximal d'éléments renvoyés par TREE (0 : pas de limite)
+
+	// Arrêt : secondes laissées aux commandes en cours avant de fermer les sessions de force (0 : pas de limite)
+	DrainTimeout int `json:"drainTimeout"`
+
+	// Délais, en secondes (0 : pas de limite, sauf pour TransferTimeout)
+	IdleTimeout        int `json:"idleTimeout"`        // attente de la prochaine commande avant de fermer la session
+	TransferTimeout    int `json:"transferTimeout"`    // lecture ou écriture sans progression pendant un échange ou un transfert
+	MaxSessionDuration int `json:"maxSessionDuration"` // durée maximale d'une session, la commande en cours est terminée
+
+	// Limites de connexions, vérifiées à l'acceptation (et au login pour l'utilisateur) ; 0 : pas de limite
+	MaxSessions        int `json:"maxSessions"`        // sessions ouvertes sur le port normal
+	MaxSessionsPerIP   int `json:"maxSessionsPerIP"`   // sessions ouvertes depuis une même adresse IP
+	MaxSessionsPerUser int `json:"maxSessionsPerUser"` // sessions authentifiées d'un même utilisateur
+
+	// TLS (optionnel) : avec un certificat, les deux ports sont chiffrés
+	CertFile    string `json:"cert"`        // certificat PEM du serveur
+	KeyFile     string `json:"key"`         // clé privée PEM du serveur
+	CAFile      string `json:"ca"`          // autorité de confiance pour les certificats clients
+	ControlMTLS bool   `json:"controlMTLS"` // exiger un certificat client sur le port de contrôle
+
+	// Authentification (optionnelle) : sans fichier d'utilisateurs, l'accès est libre
+	UsersFile string `json:"users"` // fichier JSON des comptes (nom, hash bcrypt, dossier, rôle, permissions)
+	AuditFile string `json:"audit"` // journal des refus de permission (stderr si vide)
+}
+
+// DefaultConfig retourne la configuration utilisée quand rien n'est précisé.
+func DefaultConfig() Config {
+	return Config{
+		Port:        "3333",
+		ControlPort: "3334",
+		Root:        "Docs",
+
+		ControlTakeover: ReprendreRefuser,
+
+		TreeMaxEntries: 10000,
+		DrainTimeout:   30,
+
+		IdleTimeout:        300,
+		TransferTimeout:    20,
+		MaxSessionDuration: 0,
+
+		MaxSessions:        100,
+		MaxSessionsPerIP:   10,
+		MaxSessionsPerUser: 5,
+	}
+}
+
+// LoadConfig lit le fichier de configuration JSON path.
+// Les clés absentes du fichier gardent la valeur qu'elles ont dans cfg.
+func LoadConfig(path string, cfg Config) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("lecture du fichier de configuration: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("fichier de configuration %s invalide: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// compteConnexions : sessions ouvertes sur le port normal, par adresse IP et par utilisateur authentifié,
+// comparées aux limites de la configuration (Config.MaxSessions...). Protégé par un mutex.
+// Le port de contrôle n'admet qu'une session (voir prendreControle) : il n'est pas compté dans le total,
+// mais son utilisateur l'est dans les sessions par utilisateur.
+type compteConnexions struct {
+	mu             sync.Mutex
+	total          int
+	parIP          map[string]int
+	parUtilisateur map[string]int
+}
+
+// connexions : sessions ouvertes, partagées par les listeners et les sessions.
+var connexions = &compteConnexions{parIP: make(map[string]int), parUtilisateur: make(map[string]int)}
+
+// adresseIP retourne l'adresse IP de addr, sans le port.
+func adresseIP(addr net.Addr) string {
+	hote, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return hote
+}
+
+// ouvrir compte une connexion acceptée depuis ip ; retourne false, avec le message de refus destiné au client,
+// si le nombre maximal de sessions (maxTotal) ou de sessions par adresse (maxParIP) est atteint. 0 : pas de limite.
+func (cc *compteConnexions) ouvrir(ip string, maxTotal, maxParIP int) (bool, string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if maxTotal > 0 && cc.total >= maxTotal {
+		return false, fmt.Sprintf("Trop de connexions : %d sessions ouvertes sur le serveur (maximum %d)", cc.total, maxTotal)
+	}
+	if maxParIP > 0 && cc.parIP[ip] >= maxParIP {
+		return false, fmt.Sprintf("Trop de connexions depuis %s : %d sessions ouvertes (maximum %d)", ip, cc.parIP[ip], maxParIP)
+	}
+	cc.total++
+	cc.parIP[ip]++
+	return true, ""
+}
+
+// fermer décompte une connexion ouverte par ouvrir, à la fin de sa session.
+func (cc *compteConnexions) fermer(ip string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.total--
+	if cc.parIP[ip]--; cc.parIP[ip] <= 0 {
+		delete(cc.parIP, ip)
+	}
+}
+
+// connecter compte une session authentifiée de l'utilisateur nom ; retourne false, avec le message de refus,
+// si l'utilisateur a déjà maxParUtilisateur sessions ouvertes (0 : pas de limite).
+func (cc *compteConnexions) connecter(nom string, maxParUtilisateur int) (bool, string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if maxParUtilisateur > 0 && cc.parUtilisateur[nom] >= maxParUtilisateur {
+		return false, fmt.Sprintf("Trop de connexions pour l'utilisateur %s : %d sessions ouvertes (maximum %d)",
+			nom, cc.parUtilisateur[nom], maxParUtilisateur)
+	}
+	cc.parUtilisateur[nom]++
+	return true, ""
+}
+
+// deconnecter décompte une session comptée par connecter, à la fin de la session.
+func (cc *compteConnexions) deconnecter(nom string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.parUtilisateur[nom]--; cc.parUtilisateur[nom] <= 0 {
+		delete(cc.parUtilisateur, nom)
+	}
+}
+
+// etat décrit les sessions ouvertes et les limites de cfg, champs "clé=valeur" séparés par des espaces :
+// "sessions=3 maxSessions=100 maxPerIP=10 maxPerUser=5 ip:127.0.0.1=2 ip:10.0.0.7=1 user:bob=1".
+// Une limite à 0 signifie pas de limite ; adresses et utilisateurs sont triés, les noms entre guillemets si nécessaire.
+func (cc *compteConnexions) etat(cfg *Config) string {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	var champs = []string{
+		"sessions=" + strconv.Itoa(cc.total),
+		"maxSessions=" + strconv.Itoa(cfg.MaxSessions),
+		"maxPerIP=" + strconv.Itoa(cfg.MaxSessionsPerIP),
+		"maxPerUser=" + strconv.Itoa(cfg.MaxSessionsPerUser),
+	}
+	var ips []string
+	for ip, n := range cc.parIP {
+		ips = append(ips, "ip:"+ip+"="+strconv.Itoa(n))
+	}
+	sort.Strings(ips)
+	var utilisateurs []string
+	for nom, n := range cc.parUtilisateur {
+		utilisateurs = append(utilisateurs, p.Quote("user:"+nom+"="+strconv.Itoa(n)))
+	}
+	sort.Strings(utilisateurs)
+	return strings.Join(append(append(champs, ips...), utilisateurs...), " ")
+}
+
+// refuserConnexion prévient le client que la limite de connexions est atteinte et ferme la connexion.
+// Appelée dans sa propre goroutine : un client lent (négociation TLS) ne bloque pas le listener.
+func refuserConnexion(conn net.Conn, motif string) {
+	log.Println("Connexion refusée pour", conn.RemoteAddr().String(), ":", motif)
+	if err := p.NewConn(conn).Interrupt(p.CodeTooManyConns, motif); err != nil {
+		log.Println("Erreur lors de l'envoi du refus de connexion:", err)
+	}
+}
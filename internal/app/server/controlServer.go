@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// Politiques de reprise du port de contrôle (Config.ControlTakeover), qui n'admet qu'une session à la fois.
+const (
+	ReprendreRefuser   = "refuse"  // une nouvelle connexion est refusée tant que la session en place est ouverte
+	ReprendreRemplacer = "replace" // une nouvelle connexion remplace la session en place, qui est prévenue et fermée
+)
+
+// Session de contrôle en place (sa connexion), protégée par un mutex.
+var (
+	detenteurControle *p.Conn
+	depuisControle    time.Time
+	controleMutex     sync.Mutex
+)
+
+// prendreControle réserve le port de contrôle pour la session de connexion c selon la politique de reprise.
+// Il n'est réservé qu'une fois la session ouverte : après le login d'un admin (LoginServer),
+// ou après "start" si aucun compte n'est configuré ; une connexion non authentifiée ne bloque pas le port.
+// Retourne false, avec le message de refus destiné au client, si une autre session le détient.
+func prendreControle(c *p.Conn, politique string) (bool, string) {
+	controleMutex.Lock()
+	defer controleMutex.Unlock()
+
+	var adresse = c.RemoteAddr().String()
+	if detenteurControle != nil {
+		var occupe = fmt.Sprintf("Port de contrôle occupé par %s depuis %s",
+			detenteurControle.RemoteAddr().String(), depuisControle.Format("15:04:05"))
+		if politique != ReprendreRemplacer {
+			log.Println("Connexion de contrôle refusée pour", adresse, ":", occupe)
+			return false, occupe
+		}
+
+		// La session en place est prévenue et fermée ; sa lecture échoue et elle se termine
+		log.Println("Session de contrôle de", detenteurControle.RemoteAddr().String(), "reprise par", adresse)
+		if err := detenteurControle.Interrupt(p.CodeControlBusy, "Session de contrôle reprise par "+adresse); err != nil {
+			log.Println("Erreur lors de la fermeture de la session de contrôle remplacée:", err)
+		}
+	}
+
+	detenteurControle = c
+	depuisControle = time.Now()
+	return true, ""
+}
+
+// rendreControle libère le port de contrôle à la fin de la session de connexion c
+// (sans effet si elle ne l'a pas réservé ou a été remplacée).
+func rendreControle(c *p.Conn) {
+	controleMutex.Lock()
+	defer controleMutex.Unlock()
+	if detenteurControle == c {
+		detenteurControle = nil
+	}
+}
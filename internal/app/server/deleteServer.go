@@ -0,0 +1,34 @@
+package server
+
+import (
+	"log"
+	"os"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// DeleteServer : supprime un fichier (ou un lien symbolique, sans toucher à sa cible) du dossier courant.
+// Répond 200 si succès, 550 si le fichier est introuvable, 553 si c'est un dossier (voir RMDIR),
+// 451 si la suppression échoue.
+func DeleteServer(c *p.Conn, fsys *vfs, req p.Request) bool {
+	path, rel, err := fsys.visible(req.Args[0])
+	var info os.FileInfo
+	if err == nil && rel != "" {
+		info, err = os.Lstat(path)
+	}
+	if err != nil || rel == "" {
+		log.Println("DELETE : fichier non trouvé:", req.Args[0])
+		return repondre(c, p.CodeFileUnknown, "Fichier introuvable", "DELETE")
+	}
+	if info.IsDir() {
+		log.Println("DELETE refusé, la cible est un dossier:", rel)
+		return repondre(c, p.CodeNameRefused, "La cible est un dossier, utiliser RMDIR", "DELETE")
+	}
+
+	if err := os.Remove(path); err != nil {
+		log.Println("Ne peut pas supprimer le fichier :", err)
+		return repondre(c, p.CodeLocalError, "Échec de la suppression sur le serveur", "DELETE")
+	}
+	log.Println("Fichier supprimé:", rel)
+	return repondre(c, p.CodeOK, "Fichier supprimé", "DELETE")
+}
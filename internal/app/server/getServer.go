@@ -1,73 +1,60 @@
 package server
 
 import (
-	"bufio"
 	"errors"
+	"io"
 	"log"
 	"net"
 	"os"
-	"path/filepath"
-	"strings"
+	"strconv"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
 // Getserver : implémentation de GET.
-// - Parcourt le dossier fourni (commGet[2]) pour trouver le fichier commGet[1].
-// - Envoie "Start", envoie le contenu si trouvé, puis attend la confirmation client.
-func Getserver(conn net.Conn, commGet []string, writer *bufio.Writer, reader *bufio.Reader) bool {
-	var fichiers, err = os.ReadDir(commGet[2])
-	if err != nil {
-		log.Println("Erreur lecture dossier Docs:", err)
-		return false
-	}
+// req : GET <filename> ou GET <filename> <offset> pour reprendre un transfert interrompu.
+// - Résout le nom depuis le dossier courant de la session, sans sortir de la racine.
+// - Envoie "150 <taille>", exactement <taille> octets bruts à partir de l'offset puis "213 <sha256>" si trouvé,
+// 550 si le fichier est introuvable, 554 si l'offset dépasse sa taille, puis attend la confirmation client
+// ("OK", ou "ChecksumMismatch" si l'empreinte calculée par le client diffère).
+// caps : fonctionnalités négociées ; sans "resume" un offset est refusé, sans "checksum" l'empreinte n'est pas envoyée.
+func Getserver(c *p.Conn, fsys *vfs, req p.Request, caps p.Capabilities) bool {
 	var found = false
 
-	for _, fichier := range fichiers {
-		if commGet[1] == fichier.Name() && !strings.HasPrefix(fichier.Name(), ".") && !fichier.IsDir() {
-			found = true
-			log.Println("Fichier trouvé:", fichier.Name())
-			var path = filepath.Join(commGet[2], fichier.Name())
-
-			if err := p.Send_message(conn, writer, "Start"); err != nil {
-				var netErr net.Error
-				if errors.As(err, &netErr) && netErr.Timeout() {
-					log.Println("Timeout lors de l'envoi de 'Start':", err)
-				}
-				return false
-			}
-
-			var data, err = os.ReadFile(path)
-			if err != nil {
-				log.Println("Ne peut pas lire le contenu du fichier :", err)
-				return false
-			}
+	path, rel, err := fsys.visible(req.Args[0])
+	if err == nil {
+		info, err := os.Stat(path)
+		found = err == nil && info.Mode().IsRegular()
+	} else if errors.Is(err, ErrHorsRacine) {
+		log.Println("Chemin refusé:", req.Args[0], err)
+	}
 
-			err = p.Send_message(conn, writer, string(data))
-			if err != nil {
-				var netErr net.Error
-				if errors.As(err, &netErr) && netErr.Timeout() {
-					log.Println("Timeout lors du transfert du fichier:", err)
-				}
-				return false
-			}
-			break
+	var offset int64
+	if len(req.Args) == 2 {
+		offset, err = strconv.ParseInt(req.Args[1], 10, 64)
+		if err != nil || !caps.Has(p.FeatureResume) {
+			offset = -1 // rejeté ci-dessous comme hors limites
 		}
 	}
 
-	if !found {
-		log.Println("Fichier non trouvé:", commGet[1])
-		if err := p.Send_message(conn, writer, "FileUnknown"); err != nil {
+	if found {
+		log.Println("Fichier trouvé:", rel)
+		if !envoyerFichier(c, path, rel, offset, caps.Has(p.FeatureChecksum)) {
+			return false
+		}
+	} else {
+		log.Println("Fichier non trouvé:", req.Args[0])
+		if err := c.SendResponse(p.CodeFileUnknown, "Fichier introuvable"); err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de l'envoi de 'FileUnknown':", err)
+				log.Println("Timeout lors de l'envoi du refus GET:", err)
 			}
 			return false
 		}
 	}
 
 	// Attendre la confirmation du client après le transfert/erreur.
-	var response, err2 = p.Receive_message(conn, reader)
+	var response, err2 = c.ReceiveRequest()
 	if err2 != nil {
 		var netErr net.Error
 		if errors.As(err2, &netErr) && netErr.Timeout() {
@@ -75,6 +62,95 @@ func Getserver(conn net.Conn, commGet []string, writer *bufio.Writer, reader *bu
 		}
 		return false
 	}
-	log.Println("Réponse du client:", response)
+	log.Println("Réponse du client:", response.Command)
+	if response.Command == "ChecksumMismatch" {
+		log.Println("Le client a reçu un fichier corrompu:", req.Args[0])
+	}
+	return true
+}
+
+// envoyerFichier envoie le contenu du fichier path à partir de offset : "150 <octets restants>", le flux brut,
+// puis "213 <sha256>" calculé sur le fichier complet si avecEmpreinte (précédé de "110" tant que le calcul dure).
+// Si offset est négatif ou dépasse la taille du fichier, répond 554 sans rien envoyer.
+// Retourne false en cas d'erreur réseau ou de lecture.
+func envoyerFichier(c *p.Conn, path string, rel string, offset int64, avecEmpreinte bool) bool {
+	fichierOuvert, err := os.Open(path)
+	if err != nil {
+		log.Println("Ne peut pas ouvrir le fichier :", err)
+		return false
+	}
+	defer fichierOuvert.Close()
+
+	// La taille est lue sur le fichier ouvert pour correspondre au flux réellement envoyé
+	fileInfo, err := fichierOuvert.Stat()
+	if err != nil {
+		log.Println("Erreur lors de la lecture du fichier:", err)
+		return false
+	}
+
+	if offset < 0 || offset > fileInfo.Size() {
+		log.Println("Offset hors limites pour", rel, ":", offset, "/", fileInfo.Size())
+		if err := c.SendResponse(p.CodeBadOffset, "Position de reprise invalide"); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Println("Timeout lors de l'envoi du refus de reprise:", err)
+			}
+			return false
+		}
+		return true
+	}
+
+	// L'empreinte couvre tout le fichier, y compris les octets déjà reçus par le client lors d'une reprise :
+	// elle est calculée en arrière-plan, pendant l'envoi, sur une lecture indépendante du fichier ouvert
+	var resultat <-chan empreinte
+	if avecEmpreinte {
+		resultat = calculerEmpreinte(io.NewSectionReader(fichierOuvert, 0, fileInfo.Size()))
+	}
+	if _, err := fichierOuvert.Seek(offset, io.SeekStart); err != nil {
+		log.Println("Erreur lors du positionnement dans le fichier:", err)
+		return false
+	}
+	var restant = fileInfo.Size() - offset
+
+	// "150 <taille>" annonce le nombre exact d'octets du flux binaire qui suit
+	if err := c.SendResponse(p.CodeStart, strconv.FormatInt(restant, 10)); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de 'Start':", err)
+		}
+		return false
+	}
+
+	if err := c.SendData(fichierOuvert, restant); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors du transfert du fichier:", err)
+		}
+		log.Println("Erreur lors du transfert du fichier:", err)
+		return false
+	}
+
+	if !avecEmpreinte {
+		log.Println("Fichier envoyé sans empreinte:", rel, restant, "octets à partir de l'offset", offset)
+		return true
+	}
+
+	// "213 <sha256>" suit les données, précédé de "110" si le calcul de l'empreinte n'est pas encore terminé
+	e, ok := attendreEmpreinte(c, resultat, "GET")
+	if !ok {
+		return false
+	}
+	if e.err != nil {
+		log.Println("Erreur lors du calcul de l'empreinte:", e.err)
+		return false
+	}
+	if err := c.SendResponse(p.CodeChecksum, e.somme); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de 'Checksum':", err)
+		}
+		return false
+	}
+	log.Println("Fichier envoyé:", rel, restant, "octets à partir de l'offset", offset)
 	return true
 }
@@ -1,320 +1,211 @@
 package server
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"log"
 	"log/slog"
 	"net"
-	"strings"
+	"strconv"
 	"time"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
 // HandleClient : logique pour un client "normal"
-func HandleClient(conn net.Conn) {
+func HandleClient(conn net.Conn, cfg *Config) {
+	log.Println("adresse IP du nouveau client :", conn.RemoteAddr().String(), " connecté le : ", time.Now(), " connecté sur le port ", cfg.Port)
+	servir(conn, cfg, PortNormal)
+}
+
+// HandleControlClient : logique pour le client de contrôle
+// Sur le port de contrôle, seul un utilisateur admin peut s'authentifier, et une seule session est ouverte à la fois.
+func HandleControlClient(conn net.Conn, cfg *Config) {
+	log.Println("adresse IP du nouveau client :", conn.RemoteAddr().String(), " connecté le : ", time.Now())
+	servir(conn, cfg, PortControle)
+}
+
+// servir : boucle de session commune aux deux ports.
+// Chaque message reçu est résolu dans le registre des commandes puis exécuté.
+func servir(conn net.Conn, cfg *Config, port Port) {
 	defer ClientLogOut(conn)
 
 	taille := incrementerClient()
 	log.Println("nombre de client : ", taille)
 
-	log.Println("adresse IP du nouveau client :", conn.RemoteAddr().String(), " connecté le : ", time.Now(), " connecté sur le port ", "3333")
+	// Dossier courant de la session, tenu côté serveur et confiné à la racine servie
+	fsys, err := newVFS(cfg.Root)
+	if err != nil {
+		log.Println("Racine servie inaccessible:", err)
+		return
+	}
+
+	c := p.NewConn(conn)
+	s := &session{
+		conn: c,
+		fsys: fsys,
+		port: port,
+		cfg:  cfg,
+	}
+	if cfg.MaxSessionDuration > 0 {
+		s.fin = time.Now().Add(time.Duration(cfg.MaxSessionDuration) * time.Second)
+	}
 
-	reader := bufio.NewReader(conn)
-	writer := bufio.NewWriter(conn)
+	// Session attendue par l'arrêt du serveur ; refusée si l'arrêt a déjà commencé
+	a := arretCourant()
+	if !a.inscrire(s) {
+		envoyerArret(s)
+		return
+	}
+	defer a.desinscrire(s)
 
-	// Envoyer greeting initial via protocole (Send_message gère le flush/format)
-	if err := p.Send_message(conn, writer, "hello"); err != nil {
+	// Session comptée pour son utilisateur au login (limite par utilisateur), décomptée à la fin
+	defer func() {
+		if s.login.user != nil {
+			connexions.deconnecter(s.login.user.Name)
+		}
+	}()
+
+	// Le port de contrôle n'admet qu'une session à la fois (voir Config.ControlTakeover), réservé à l'ouverture de la session
+	if port == PortControle {
+		defer rendreControle(c)
+	}
+
+	// Envoyer greeting initial via protocole (SendResponse gère le flush/format)
+	// Le greeting annonce la version du protocole, les fonctionnalités du serveur
+	// et la position de départ du client dans l'arborescence : "220 <version> <f1,f2,...> <position>"
+	// Le délai d'inactivité est annoncé parmi les fonctionnalités (idle=<secondes>) pour régler les NOOP du client
+	annonce := p.Local()
+	if cfg.IdleTimeout > 0 {
+		annonce = annonce.WithParam(p.ParamIdle, strconv.Itoa(cfg.IdleTimeout))
+	}
+	if err := c.SendResponse(p.CodeHello, annonce.String()+" "+fsys.position()); err != nil {
 		// Sensible aux erreurs réseau (timeouts etc.)
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			log.Println("Timeout lors de l'envoi de 'hello':", err)
+		} else {
+			// ex : échec de la négociation TLS
+			log.Println("Erreur lors de l'envoi de 'hello':", err)
 		}
 		return
 	}
 
 	for {
-		// Boucle de réception de commandes
-		msg, err := p.Receive_message(conn, reader)
+		// Si le serveur est en cours d'arrêt : informer le client et couper la connexion
+		if !a.attendreCommande(s) {
+			envoyerArret(s)
+			return
+		}
+
+		// Durée maximale de la session atteinte pendant la commande précédente
+		attente, ok := s.attenteCommande()
+		if !ok {
+			envoyerExpiration(s)
+			return
+		}
+
+		// Boucle de réception de commandes : le client a attente pour envoyer la suivante
+		req, err := c.WaitRequest(attente)
+		if !a.commencerCommande(s) {
+			// Arrêt commencé pendant l'attente : la session a pu être déconnectée (lecture en erreur)
+			if err == nil || errors.Is(err, p.ErrSyntax) {
+				envoyerArret(s)
+			}
+			return
+		}
+		if errors.Is(err, p.ErrSyntax) {
+			// Requête mal formée (guillemet non fermé...) : rejetée, la session continue
+			log.Println("Requête mal formée:", err)
+			if !envoyerReponse(s, p.CodeSyntaxError, "Requête mal formée : "+err.Error()) {
+				return
+			}
+			continue
+		}
 		if err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de la réception d'un message client:", err)
+				// Client inactif (ou session arrivée à sa durée maximale) : prévenu avant la fermeture
+				envoyerExpiration(s)
 			}
 			return
 		}
 
-		cleanedMsg := strings.TrimSpace(msg)
-		log.Println(cleanedMsg)
-
-		var commGet = strings.Split(cleanedMsg, " ")
-
-		// Si le serveur n'est pas en train de se terminer, traiter les commandes
-		if !isServerShuttingDown() {
-			log.Println("cleanedMessage :", cleanedMsg)
-			if cleanedMsg == "start" {
-				// Répondre OK pour démarrer la session.
-				if err := p.Send_message(conn, writer, "ok"); err != nil {
-					var netErr net.Error
-					if errors.As(err, &netErr) && netErr.Timeout() {
-						log.Println("Timeout lors de l'envoi de 'ok' après 'start':", err)
-					}
-					return
-				}
-
-				// LIST : envoie la liste des commandes que le client peut utiliser
-			} else if len(commGet) == 2 && commGet[0] == "List" {
-				nbOp := incrementerOperations()
-				log.Println("Commande LIST reçue, opérations en cours:", nbOp)
-				if !ListServer(conn, commGet, writer, reader) {
-					// En cas d'erreur, décrémenter et quitter.
-					decrementerOperations()
-					return
-				}
-				nbOp = decrementerOperations()
-				log.Println("Commande LIST terminée, opérations restantes:", nbOp)
-
-				// GET : transfert d'un fichier
-			} else if len(commGet) == 3 && commGet[0] == "GET" {
-				nbOp := incrementerOperations()
-				log.Println("Commande GET reçue pour:", commGet[1], ", opérations en cours:", nbOp)
-				if !Getserver(conn, commGet, writer, reader) {
-					decrementerOperations()
-					return
-				}
-				nbOp = decrementerOperations()
-				log.Println("Commande GET terminée, opérations restantes:", nbOp)
+		log.Println("requête :", req.String())
 
-				// UNKNOWN : envoie le message d'aide si la commande envoyee n'est pas reconnue
-			} else if cleanedMsg == "Unknown" {
-				// Commande inconnue : renvoyer message d'aide.
-				if err := p.Send_message(conn, writer, "Commande inconnue. Veuillez entrer HELP pour avoir la liste de commande."); err != nil {
-					var netErr net.Error
-					if errors.As(err, &netErr) && netErr.Timeout() {
-						log.Println("Timeout lors de l'envoi du message unknown:", err)
-					}
-					return
-				}
-				log.Println("Commande inconnue. Veuillez entrer HELP pour avoir la liste de commande.")
-
-				// HELP : le client reçoit la liste des commandes qu'il peut effectuer
-			} else if commGet[0] == "Help" {
-				helpMessage := "Commandes disponibles : LIST, GET <filename>, GOTO <target>, TREE, HELP, END"
-				if commGet[1] == "true" {
-					helpMessage += ", MESSAGES"
-				}
-				if err := p.Send_message(conn, writer, helpMessage); err != nil {
-					var netErr net.Error
-					if errors.As(err, &netErr) && netErr.Timeout() {
-						log.Println("Timeout lors de l'envoi de 'help':", err)
-					}
-					return
-				}
-
-			} else if commGet[0] == "tree" {
-				// Envoie l'arbre récursif du dossier "Docs"
-				if !tree(conn, writer, reader) {
-					return
-				}
-
-			} else if commGet[0] == "GOTO" {
-				if !GOTO(commGet, conn, writer) { // Appel avec vérification de l'échec réseau
-					return // Fermer la connexion en cas d'erreur Send_message/Timeout dans GOTO
-				}
-
-			} else if cleanedMsg == "end" {
-				// Fin de la session cliente.
-				if err := p.Send_message(conn, writer, "ok"); err != nil {
-					var netErr net.Error
-					if errors.As(err, &netErr) && netErr.Timeout() {
-						log.Println("Timeout lors de l'envoi de 'ok' après 'end':", err)
-					}
-				}
+		if !s.login.authentifie() && !commandeHorsLogin(req) {
+			// Tant que l'authentification n'a pas réussi, seules les commandes de login sont acceptées ;
+			// sur le port de contrôle, la connexion est fermée dès la première commande refusée
+			if !envoyerReponse(s, p.CodeLoginRequired, "Authentification requise") || port == PortControle {
 				return
+			}
 
-			} else {
-				// Message inattendu : on l'ignore (mais on log)
-				log.Println("Message inattendu du client:", cleanedMsg)
-				continue
+		} else if commande, code, texte := commandes.Lookup(req, port, s.caps); commande == nil {
+			// Commande inconnue, indisponible ou mal formée : le client reçoit toujours une réponse
+			log.Println("Message inattendu du client:", req.String())
+			if !envoyerReponse(s, code, texte) {
+				return
 			}
 
-		} else {
-			// Si le serveur est en cours d'arrêt : informer le client et couper la connexion
-			if err := p.Send_message(conn, writer, "Server terminating, connection closing."); err != nil {
-				var netErr net.Error
-				if errors.As(err, &netErr) && netErr.Timeout() {
-					log.Println("Timeout lors de l'envoi du message de terminaison:", err)
-				}
+		} else if !s.login.autorise(fsys, commande, req, conn.RemoteAddr().String()) {
+			// Permission requise par la commande, vérifiée avant toute exécution
+			if !envoyerReponse(s, p.CodePermissionDenied, "Permission refusée") {
+				return
 			}
+
+		} else if !commande.Run(s, req) {
 			return
 		}
 
 		// Si le logger est en mode debug, on renvoie des infos de debug au client
 		if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
 			log.Println("debug ")
-			DebugServer(conn, writer)
+			DebugServer(c)
 		}
 	}
 }
 
-// HandleControlClient : logique pour le client de contrôle (suppression de l'ancienne logique d'historique)
-func HandleControlClient(conn net.Conn) {
-	defer ClientLogOut(conn)
-
-	taille := incrementerClient()
-	log.Println("nombre de client : ", taille)
-
-	log.Println("adresse IP du nouveau client :", conn.RemoteAddr().String(), " connecté le : ", time.Now())
-
-	reader := bufio.NewReader(conn)
-	writer := bufio.NewWriter(conn)
-
-	if err := p.Send_message(conn, writer, "hello"); err != nil {
+// envoyerArret informe le client que le serveur s'arrête et que sa connexion va être fermée.
+func envoyerArret(s *session) {
+	if err := s.conn.SendResponse(p.CodeShuttingDown, messageArret); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
-			log.Println("Timeout lors de l'envoi de 'hello':", err)
+			log.Println("Timeout lors de l'envoi du message de terminaison:", err)
 		}
-		return
 	}
+}
 
-	for {
-		msg, err := p.Receive_message(conn, reader)
-		if err != nil {
-			var netErr net.Error
-			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de la réception d'un message client control:", err)
-			}
-			return
-		}
-
-		cleanedMsg := strings.TrimSpace(msg)
-		log.Println(cleanedMsg)
-
-		var commHideReveal = strings.Split(cleanedMsg, " ")
-
-		if !isServerShuttingDown() {
-			log.Println("cleanedMessage :", cleanedMsg)
-			if cleanedMsg == "start" {
-				if err := p.Send_message(conn, writer, "ok"); err != nil {
-					var netErr net.Error
-					if errors.As(err, &netErr) && netErr.Timeout() {
-						log.Println("Timeout lors de l'envoi de 'ok' après 'start':", err)
-					}
-					return
-				}
-
-				// LIST : envoie la liste des commandes que le client peut utiliser
-			} else if len(commHideReveal) == 2 && commHideReveal[0] == "List" {
-				nbOp := incrementerOperations()
-				log.Println("Commande LIST reçue, opérations en cours:", nbOp)
-				if !ListServer(conn, commHideReveal, writer, reader) {
-					decrementerOperations()
-					return
-				}
-				nbOp = decrementerOperations()
-				log.Println("Commande LIST terminée, opérations restantes:", nbOp)
-
-				// TERMINATE : permet d'éteindre le serveur et de déconnecter les autres clients
-			} else if cleanedMsg == "Terminate" {
-				// Stocker les flux du client initiant la terminaison pour pouvoir
-				// lui envoyer des messages d'état durant l'arrêt.
-				log.Println("Commande TERMINATE reçue")
-				clientTerminantMutex.Lock()
-				clientTerminant.reader = reader
-				clientTerminant.writer = writer
-				clientTerminantMutex.Unlock()
-				// Lancer la procédure de terminaison dans une goroutine séparée
-				go TerminateServer(conn)
-				// Attendre que shutdownChan soit fermé (TerminateServer le ferme).
-				<-shutdownChan
-				return
-
-				// UNKNOWN : envoie le message d'aide si la commande envoyee n'est pas reconnue
-			} else if cleanedMsg == "Unknown" {
-				if err := p.Send_message(conn, writer, "Commande inconnue. Veuillez entrer HELP pour avoir la liste de commande."); err != nil {
-					var netErr net.Error
-					if errors.As(err, &netErr) && netErr.Timeout() {
-						log.Println("Timeout lors de l'envoi du message unknown:", err)
-					}
-					return
-				}
-				log.Println("Commande inconnue. Veuillez entrer HELP pour avoir la liste de commande.")
-
-				// HELP : le client reçoit la liste des commandes qu'il peut effectuer
-			} else if commHideReveal[0] == "Help" {
-				helpMessage := "Commandes disponibles : LIST, HIDE <filename>, REVEAL <filename>, GOTO <target>, TREE, HELP, END, TERMINATE"
-				if commHideReveal[1] == "true" {
-					helpMessage += ", MESSAGES"
-				}
-				if err := p.Send_message(conn, writer, helpMessage); err != nil {
-					var netErr net.Error
-					if errors.As(err, &netErr) && netErr.Timeout() {
-						log.Println("Timeout lors de l'envoi de 'help':", err)
-					}
-					return
-				}
-
-				// HIDE <file> : permet de cacher un fichier visible
-			} else if len(commHideReveal) == 3 && commHideReveal[0] == "HIDE" {
-				nbOp := incrementerOperations()
-				log.Println("Commande HIDE reçue, opérations en cours:", nbOp)
-				if !HIDE(conn, commHideReveal, writer) {
-					decrementerOperations()
-					return
-				}
-				nbOp = decrementerOperations()
-				log.Println("Commande HIDE terminée, opérations restantes:", nbOp)
-
-				// REVEAL <file> : permet de révéler un fichier caché
-			} else if len(commHideReveal) == 3 && commHideReveal[0] == "REVEAL" {
-				nbOp := incrementerOperations()
-				log.Println("Commande REVEAL reçue, opérations en cours:", nbOp)
-				if !REVEAL(conn, commHideReveal, writer) {
-					decrementerOperations()
-					return
-				}
-				nbOp = decrementerOperations()
-				log.Println("Commande REVEAL terminée, opérations restantes:", nbOp)
-
-				// END : pour la deconnexion du client
-			} else if cleanedMsg == "end" {
-				if err := p.Send_message(conn, writer, "ok"); err != nil {
-					var netErr net.Error
-					if errors.As(err, &netErr) && netErr.Timeout() {
-						log.Println("Timeout lors de l'envoi de 'ok' après 'end':", err)
-					}
-				}
-				return
-
-			} else if commHideReveal[0] == "tree" {
-				tree(conn, writer, reader)
-
-			} else if commHideReveal[0] == "GOTO" {
-				if !GOTO(commHideReveal, conn, writer) {
-					return
-				}
-
-			} else {
-				log.Println("Message inattendu du client:", cleanedMsg)
-				continue
-			}
-
-		} else {
-			// Si le serveur est en cours d'arrêt : informer le client et couper la connexion.
-			if err := p.Send_message(conn, writer, "Server terminating, connection closing."); err != nil {
-				var netErr net.Error
-				if errors.As(err, &netErr) && netErr.Timeout() {
-					log.Println("Timeout lors de l'envoi du message de terminaison:", err)
-				}
-			}
-			return
-		}
+// delaiLoginControle : délai laissé à une connexion au port de contrôle pour s'authentifier, entre deux commandes.
+const delaiLoginControle = 30 * time.Second
+
+// attenteCommande retourne le délai laissé au client pour envoyer sa prochaine commande : le délai d'inactivité
+// (Config.IdleTimeout, delaiLoginControle avant l'authentification sur le port de contrôle),
+// raccourci pour ne pas dépasser la durée maximale de la session. 0 : pas de limite.
+// ok vaut false si la durée maximale de la session est déjà atteinte.
+func (s *session) attenteCommande() (attente time.Duration, ok bool) {
+	attente = time.Duration(s.cfg.IdleTimeout) * time.Second
+	if s.port == PortControle && !s.login.authentifie() && (attente == 0 || attente > delaiLoginControle) {
+		attente = delaiLoginControle
+	}
+	if s.fin.IsZero() {
+		return attente, true
+	}
+	restant := time.Until(s.fin)
+	if restant <= 0 {
+		return 0, false
+	}
+	if attente == 0 || restant < attente {
+		attente = restant
+	}
+	return attente, true
+}
 
-		if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
-			log.Println("debug ")
-			DebugServer(conn, writer)
-		}
+// envoyerExpiration informe le client que sa session expire (inactivité ou durée maximale) avant de fermer la connexion.
+func envoyerExpiration(s *session) {
+	var motif = "Délai d'inactivité dépassé, connexion fermée"
+	if !s.fin.IsZero() && !time.Now().Before(s.fin) {
+		motif = "Durée maximale de session atteinte, connexion fermée"
 	}
+	log.Println(motif+" :", s.conn.RemoteAddr().String())
+	envoyerReponse(s, p.CodeExpired, motif)
 }
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"log"
+	"os"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// HiddenServer : liste les éléments cachés par HIDE dans toute la racine servie (port de contrôle),
+// puis ceux cachés par leur nom commençant par '.' (voir registreCaches.cachesParPoint), que REVEAL peut aussi révéler.
+// Protocole : comme LIST, "150", attente de "OK", un "151 <chemin> <taille>" par élément, puis "226 <N>".
+// Les chemins sont relatifs à la racine servie (Config.Root), quel que soit le dossier de la session ; un dossier se terminant par '/' ;
+// un élément supprimé hors du serveur depuis qu'il a été caché est listé avec la taille -1.
+func HiddenServer(c *p.Conn, fsys *vfs) bool {
+	var flux = &fluxListe{c: c, commande: "HIDDEN"}
+	if !flux.commencer("Fichiers cachés à suivre") {
+		return false
+	}
+
+	var err error
+	for _, rel := range append(fsys.caches().liste(), fsys.caches().cachesParPoint()...) {
+		var element = p.Entry{Name: rel, Size: -1}
+		if info, errInfo := os.Lstat(fsys.caches().surDisque(rel)); errInfo == nil {
+			element.Size = info.Size()
+			if info.IsDir() {
+				element.Name += "/"
+			}
+		} else {
+			log.Println("Fichier caché introuvable sur le disque:", rel)
+		}
+		if err = flux.envoyer(p.FormatEntry(element)); err != nil {
+			break
+		}
+	}
+	return flux.terminer(err)
+}
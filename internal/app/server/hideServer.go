@@ -1,59 +1,48 @@
 package server
 
 import (
-	"bufio"
 	"errors"
 	"log"
 	"net"
 	"os"
-	"path/filepath"
-	"strings"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
-// HIDE : renomme le fichier en le préfixant par '.' pour le cacher.
-// Envoie OK si succès, FileUnknown si fichier non trouvé.
-func HIDE(conn net.Conn, commHideReveal []string, writer *bufio.Writer) bool {
-	var fichiers, err = os.ReadDir(commHideReveal[2]) // recupere le repertoire
-	if err != nil {
-		log.Println("Erreur lecture dossier:", err)
-		return false
-	}
+// HIDE : cache un fichier ou un dossier en l'ajoutant au registre des éléments cachés (voir registreCaches).
+// Le fichier n'est pas modifié sur le disque.
+// Répond 200 si succès, 550 si fichier non trouvé, 451 si le registre ne peut pas être enregistré.
+func HIDE(c *p.Conn, fsys *vfs, req p.Request) bool {
 	var found = false
 
-	for _, fichier := range fichiers { // trouver le fichier dans le repertoire
-		if commHideReveal[1] == fichier.Name() && !strings.HasPrefix(fichier.Name(), ".") { // fichier trouvé
-			found = true
-			log.Println("Fichier trouvé:", fichier.Name())
-			var oldPath = filepath.Join(commHideReveal[2], fichier.Name())
-			var newPath = filepath.Join(commHideReveal[2], "."+fichier.Name()) // ajoute un "." devant le fichier
-
-			err := os.Rename(oldPath, newPath)
-			if err != nil {
-				log.Println("Ne peut pas rename le fichier :", err)
-				return false
-			}
-			log.Println("Le fichier a bien été HIDE")
-
-			if err := p.Send_message(conn, writer, "OK"); err != nil {
-				var netErr net.Error
-				if errors.As(err, &netErr) && netErr.Timeout() {
-					log.Println("Timeout lors de l'envoi de 'OK' HIDE:", err)
-				}
-				return false
-			}
-			break
-		}
+	oldPath, rel, err := fsys.visible(req.Args[0]) // résout le fichier depuis le dossier courant
+	if err == nil && rel != "" {                   // la racine elle-même ne peut pas être cachée
+		_, err = os.Lstat(oldPath)
+		found = err == nil
 	}
 
-	// gestion du fileUnknown
-	if !found {
-		log.Println("Fichier non trouvé:", commHideReveal[1])
-		if err := p.Send_message(conn, writer, "FileUnknown"); err != nil {
+	if found { // fichier trouvé
+		log.Println("Fichier trouvé:", rel)
+
+		if err := fsys.caches().cacher(fsys.dansRegistre(rel)); err != nil {
+			log.Println("Ne peut pas enregistrer le registre des fichiers cachés :", err)
+			return repondre(c, p.CodeLocalError, "Échec de l'enregistrement sur le serveur", "HIDE")
+		}
+		log.Println("Le fichier a bien été HIDE")
+
+		if err := c.SendResponse(p.CodeOK, "Fichier caché"); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Println("Timeout lors de l'envoi de 'OK' HIDE:", err)
+			}
+			return false
+		}
+	} else { // gestion du fileUnknown
+		log.Println("Fichier non trouvé:", req.Args[0])
+		if err := c.SendResponse(p.CodeFileUnknown, "Fichier introuvable"); err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de l'envoi de 'FileUnknown' HIDE:", err)
+				log.Println("Timeout lors de l'envoi du refus HIDE:", err)
 			}
 			return false
 		}
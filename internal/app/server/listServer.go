@@ -1,72 +1,226 @@
 package server
 
 import (
-	"bufio"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"path"
 	"strconv"
-	"strings"
+	"unicode/utf8"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
-// ListServer : envoie la liste des fichiers non cachés dans le dossier demandé.
-// Protocole : envoie "Start", attend "OK" du client, puis envoie "FileCnt : N --name size ..."
-func ListServer(conn net.Conn, commHideReveal []string, writer *bufio.Writer, reader *bufio.Reader) bool {
-	var fichiers, err = os.ReadDir(commHideReveal[1])
+// tailleLot : nombre d'éléments lus à la fois dans un dossier. Un dossier n'est jamais chargé en entier :
+// chaque lot est envoyé avant la lecture du suivant.
+const tailleLot = 256
+
+// errArretParcours : renvoyée par la fonction de traitement de parcourirDossier pour arrêter la lecture
+// (limite d'éléments atteinte) ; ce n'est pas une erreur de lecture.
+var errArretParcours = errors.New("parcours interrompu")
+
+// ListServer : envoie la liste des fichiers non cachés du dossier courant de la session,
+// ou du sous-dossier passé en argument s'il est fourni.
+// Protocole : envoie "150", attend "OK" du client, puis un "151 <nom> <taille>" par fichier (voir proto.FormatEntry),
+// lus au fil de l'eau dans le dossier, et enfin "226 <N>".
+// Avec l'option -json (fonctionnalité "json" négociée), chaque élément est un objet JSON proto.FileInfo ;
+// les éléments cachés (nom commençant par '.' ou caché par HIDE) y figurent, marqués "hidden",
+// seulement si avecCaches (port de contrôle).
+func ListServer(c *p.Conn, fsys *vfs, req p.Request, caps p.Capabilities, avecCaches bool) bool {
+	args, enJSON := p.CutOption(req.Args, p.OptionJSON)
+	if enJSON && !caps.Has(p.FeatureJSON) {
+		return refuserListe(c, p.CodeOptionRefused, "Option -json non négociée", "LIST")
+	}
+	if len(args) > 1 {
+		return refuserListe(c, p.CodeSyntaxError, "Usage : LIST [dir] [-json]", "LIST")
+	}
+
+	var dossier = "."
+	if len(args) == 1 {
+		dossier = args[0]
+	}
+
+	var f *os.File
+	reel, rel, err := fsys.visible(dossier)
+	if err == nil {
+		f, err = ouvrirDossier(reel)
+	}
 	if err != nil {
-		log.Println("Erreur lecture dossier Docs:", err)
+		log.Println("Erreur lecture dossier:", dossier, err)
+		return refuserListe(c, p.CodeFileUnknown, "Dossier introuvable", "LIST")
+	}
+	defer f.Close()
+
+	var flux = &fluxListe{c: c, commande: "LIST"}
+	if !flux.commencer("Liste à suivre") {
 		return false
 	}
 
-	var list = ""
-	var size = 0
+	err = parcourirDossier(f, func(fichier os.DirEntry) error {
+		var relFichier = path.Join(rel, p.NormalizeName(fichier.Name()))
+		var cache = fsys.cache(relFichier)
+		if (cache && !(enJSON && avecCaches)) || !nomTransmissible(fichier.Name()) || fsys.interne(relFichier) {
+			return nil
+		}
+		fileInfo, err := fichier.Info()
+		if err != nil {
+			log.Println("Erreur lors de la lecture du fichier:", err)
+			return nil
+		}
+		if enJSON {
+			var element = decrire(fileInfo)
+			element.Hidden = cache
+			return flux.envoyerJSON(element)
+		}
+		return flux.envoyer(p.FormatEntry(p.Entry{Name: p.NormalizeName(fichier.Name()), Size: fileInfo.Size()}))
+	})
+	return flux.terminer(err)
+}
+
+// nomTransmissible indique si un nom de fichier peut être envoyé au client : le protocole est en UTF-8,
+// un nom qui n'en est pas ne pourrait pas être redemandé. Il est ignoré dans les listes (et loggé).
+func nomTransmissible(nom string) bool {
+	if !utf8.ValidString(nom) {
+		log.Printf("Nom non UTF-8 ignoré: %q\n", nom)
+		return false
+	}
+	return true
+}
+
+// refuserListe répond par un refus avant l'envoi d'une liste (LIST ou TREE) ; false en cas d'erreur réseau.
+func refuserListe(c *p.Conn, code p.Code, texte string, commande string) bool {
+	if err := c.SendResponse(code, texte); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi du refus "+commande+":", err)
+		}
+		return false
+	}
+	return true
+}
+
+// ouvrirDossier ouvre le dossier réel dossier pour le lire par lots ; erreur si ce n'est pas un dossier.
+func ouvrirDossier(dossier string) (*os.File, error) {
+	f, err := os.Open(dossier)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil && !info.IsDir() {
+		err = fmt.Errorf("%s n'est pas un dossier", dossier)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// parcourirDossier lit le dossier f par lots de tailleLot éléments et appelle traiter pour chacun,
+// dans l'ordre du disque. Le parcours s'arrête à la première erreur renvoyée par traiter.
+func parcourirDossier(f *os.File, traiter func(os.DirEntry) error) error {
+	for {
+		lot, err := f.ReadDir(tailleLot)
+		for _, fichier := range lot {
+			if err := traiter(fichier); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// fluxListe : envoi d'une liste (LIST, TREE, MLSD) élément par élément, voir proto/listing.go.
+type fluxListe struct {
+	c        *p.Conn
+	commande string
+	ok       bool // le client a confirmé par "OK" : les éléments peuvent être envoyés
+	nombre   int  // nombre d'éléments envoyés
+}
 
-	if err := p.Send_message(conn, writer, "Start"); err != nil {
+// commencer envoie "150 <texte>" et attend la confirmation "OK" du client ; false en cas d'erreur réseau.
+// Si le client ne répond pas "OK", les éléments ne sont pas envoyés et la liste est vide.
+func (f *fluxListe) commencer(texte string) bool {
+	if err := f.c.SendResponse(p.CodeStart, texte); err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
-			log.Println("Timeout lors de l'envoi de 'Start' LIST:", err)
+			log.Println("Timeout lors de l'envoi de 'Start' "+f.commande+":", err)
 		}
 		return false
 	}
 
-	log.Println(fichiers)
-	data, err := p.Receive_message(conn, reader)
+	data, err := f.c.ReceiveRequest()
 	if err != nil {
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
-			log.Println("Timeout lors de la réception de la confirmation LIST:", err)
+			log.Println("Timeout lors de la réception de la confirmation "+f.commande+":", err)
 		}
 		return false
 	}
-	log.Println("data:", data)
-
-	if strings.TrimSpace(data) == "OK" {
-		for _, fichier := range fichiers {
-			// Ignorer les fichiers cachés (commençant par '.')
-			if fichier.Name()[0] != '.' {
-				fileInfo, err := fichier.Info()
-				if err != nil {
-					log.Println("Erreur lors de la lecture du fichier:", err)
-					return false
-				}
-				list = list + " --" + fichier.Name() + " " + strconv.FormatInt(fileInfo.Size(), 10)
-				size = size + 1
-			}
+	f.ok = data.Command == "OK"
+	if !f.ok {
+		log.Println("Protocole "+f.commande+" : attendu 'OK', reçu:", data.Command)
+	}
+	return true
+}
+
+// envoyer envoie un élément "151 <element>". Une erreur réseau arrête le parcours du dossier.
+func (f *fluxListe) envoyer(element string) error {
+	if !f.ok {
+		return errArretParcours
+	}
+	if err := f.c.SendResponse(p.CodeEntry, element); err != nil {
+		return fmt.Errorf("envoi d'un élément %s : %w", f.commande, err)
+	}
+	f.nombre++
+	return nil
+}
+
+// envoyerJSON envoie un élément encodé en JSON sur une ligne.
+func (f *fluxListe) envoyerJSON(element p.FileInfo) error {
+	data, err := json.Marshal(element)
+	if err != nil {
+		log.Println("Erreur lors de l'encodage JSON de", element.Name, ":", err)
+		return nil
+	}
+	return f.envoyer(string(data))
+}
+
+// tronquer prévient le client, avant la fin de la liste, que des éléments ont été omis.
+func (f *fluxListe) tronquer(texte string) error {
+	log.Println(f.commande, ":", texte)
+	return f.c.SendResponse(p.CodeTruncated, texte)
+}
+
+// terminer envoie le marqueur de fin "226 <N>" après le parcours, dont err est le résultat.
+// Une erreur de lecture du dossier est loggée et la liste reçue reste partielle ; false en cas d'erreur réseau.
+func (f *fluxListe) terminer(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la liste "+f.commande+":", err)
 		}
+		return false
+	}
+	if err != nil && !errors.Is(err, errArretParcours) {
+		log.Println("Erreur lors de la lecture du dossier ("+f.commande+"):", err)
 	}
 
-	var newlist = "FileCnt : " + strconv.Itoa(size) + list
-	log.Println(newlist)
-	if err := p.Send_message(conn, writer, newlist); err != nil {
-		var netErr net.Error
+	log.Println(f.commande, ":", f.nombre, "éléments envoyés")
+	if err := f.c.SendResponse(p.CodeListing, strconv.Itoa(f.nombre)); err != nil {
 		if errors.As(err, &netErr) && netErr.Timeout() {
-			log.Println("Timeout lors de l'envoi de la liste:", err)
+			log.Println("Timeout lors de l'envoi de la fin de liste "+f.commande+":", err)
 		}
 		return false
 	}
-
 	return true
 }
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// MkdirServer : crée un dossier depuis le dossier courant ; son dossier parent doit exister.
+// Répond 200 si succès, 550 si le dossier parent est introuvable, 551 si le nom existe déjà,
+// 553 si le nom est refusé (caché ou hors de la racine), 451 si la création échoue.
+func MkdirServer(c *p.Conn, fsys *vfs, req p.Request) bool {
+	path, rel, err := fsys.visible(req.Args[0])
+	if err != nil || rel == "" {
+		log.Println("MKDIR refusé pour:", req.Args[0])
+		return repondre(c, p.CodeNameRefused, "Nom de dossier refusé", "MKDIR")
+	}
+	if !estDossier(filepath.Dir(path)) {
+		log.Println("MKDIR : dossier parent introuvable:", rel)
+		return repondre(c, p.CodeFileUnknown, "Dossier parent introuvable", "MKDIR")
+	}
+
+	if err := os.Mkdir(path, 0755); errors.Is(err, fs.ErrExist) {
+		log.Println("MKDIR refusé, le nom existe déjà:", rel)
+		return repondre(c, p.CodeFileExists, "Le dossier existe déjà", "MKDIR")
+	} else if err != nil {
+		log.Println("Ne peut pas créer le dossier :", err)
+		return repondre(c, p.CodeLocalError, "Échec de la création sur le serveur", "MKDIR")
+	}
+	log.Println("Dossier créé:", rel)
+	return repondre(c, p.CodeOK, "Dossier créé", "MKDIR")
+}
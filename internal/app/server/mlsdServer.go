@@ -0,0 +1,61 @@
+package server
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// MlsdServer : liste détaillée du dossier courant de la session, ou du sous-dossier passé en argument.
+// Chaque élément indique son type (file/dir/symlink), sa taille, sa date de modification, ses permissions,
+// son propriétaire et s'il est caché ; les éléments cachés ne sont listés que si avecCaches (port de contrôle).
+// Protocole : comme LIST, "150", attente de "OK", un "151 <élément>" par fichier (voir proto.FormatDetail), puis "226 <N>".
+func MlsdServer(c *p.Conn, fsys *vfs, req p.Request, avecCaches bool) bool {
+	var dossier = "."
+	if len(req.Args) == 1 {
+		dossier = req.Args[0]
+	}
+
+	var f *os.File
+	reel, rel, err := fsys.visible(dossier)
+	if err == nil {
+		f, err = ouvrirDossier(reel)
+	}
+	if err != nil {
+		log.Println("Erreur lecture dossier:", dossier, err)
+		return refuserListe(c, p.CodeFileUnknown, "Dossier introuvable", "MLSD")
+	}
+	defer f.Close()
+
+	var flux = &fluxListe{c: c, commande: "MLSD"}
+	if !flux.commencer("Liste détaillée à suivre") {
+		return false
+	}
+
+	err = parcourirDossier(f, func(fichier os.DirEntry) error {
+		var relFichier = path.Join(rel, p.NormalizeName(fichier.Name()))
+		var cache = fsys.cache(relFichier)
+		if (cache && !avecCaches) || !nomTransmissible(fichier.Name()) || fsys.interne(relFichier) {
+			return nil
+		}
+		fileInfo, err := fichier.Info()
+		if err != nil {
+			log.Println("Erreur lors de la lecture du fichier:", err)
+			return nil
+		}
+		var element = decrire(fileInfo)
+		element.Hidden = cache
+		return flux.envoyer(p.FormatDetail(element))
+	})
+	return flux.terminer(err)
+}
+
+// decrire décrit un élément lu par os.DirEntry.Info(), propriétaire compris.
+func decrire(info fs.FileInfo) p.FileInfo {
+	var element = p.NewFileInfo(info)
+	element.Owner = proprietaire(info)
+	return element
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package server
+
+import "io/fs"
+
+// proprietaire : le propriétaire d'un fichier n'est pas fourni sur ce système.
+func proprietaire(info fs.FileInfo) string {
+	return ""
+}
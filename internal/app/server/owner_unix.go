@@ -0,0 +1,33 @@
+//go:build unix
+
+package server
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// nomsProprietaires : cache uid -> nom d'utilisateur, pour ne pas relire la base des comptes à chaque élément.
+var nomsProprietaires sync.Map
+
+// proprietaire retourne le nom du propriétaire de info, ou son uid si le nom est inconnu.
+func proprietaire(info fs.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	uid := strconv.FormatUint(uint64(stat.Uid), 10)
+	if nom, ok := nomsProprietaires.Load(uid); ok {
+		return nom.(string)
+	}
+
+	var nom = uid
+	if u, err := user.LookupId(uid); err == nil {
+		nom = u.Username
+	}
+	nomsProprietaires.Store(uid, nom)
+	return nom
+}
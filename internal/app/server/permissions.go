@@ -0,0 +1,150 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// Permission : droit nécessaire pour exécuter une famille de commandes.
+type Permission string
+
+const (
+	PermRead  Permission = "read"  // List, MLSD, tree, GOTO, GET, SUM
+	PermWrite Permission = "write" // PUT, DELETE, RENAME, MKDIR, RMDIR
+	PermHide  Permission = "hide"  // HIDE, REVEAL, HIDDEN
+	PermAdmin Permission = "admin" // Terminate, STATUS
+)
+
+// La permission requise par chaque commande est déclarée à son enregistrement (voir commands.go).
+
+// permissionsParDefaut : droits d'un compte dont le fichier des utilisateurs ne précise pas les permissions.
+var permissionsParDefaut = map[string][]Permission{
+	RoleAdmin: {PermRead, PermWrite, PermHide, PermAdmin},
+	"user":    {PermRead, PermWrite},
+}
+
+// RegleDossier remplace les permissions d'un utilisateur dans une sous-arborescence de son dossier.
+type RegleDossier struct {
+	Path        string       `json:"path"` // chemin relatif au dossier de l'utilisateur (ex : "docs/prive")
+	Permissions []Permission `json:"permissions"`
+}
+
+// audit : journal des refus de permission (stderr par défaut, fichier avec -audit).
+var audit = log.New(os.Stderr, "AUDIT ", log.LstdFlags)
+
+// ouvrirAudit redirige le journal d'audit vers le fichier path (ajout en fin de fichier).
+func ouvrirAudit(path string) error {
+	fichier, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("ouverture du journal d'audit: %w", err)
+	}
+	audit.SetOutput(fichier)
+	return nil
+}
+
+// permissions retourne les droits de l'utilisateur sur le chemin rel (relatif à son dossier) :
+// ceux de la règle de dossier la plus précise qui contient rel, sinon ses droits généraux.
+func (u *User) permissions(rel string) []Permission {
+	var meilleure = -1
+	var droits = u.Permissions
+	if droits == nil {
+		droits = permissionsParDefaut[u.Role]
+	}
+	for _, regle := range u.Rules {
+		chemin := strings.Trim(regle.Path, "/")
+		if chemin == "." {
+			chemin = ""
+		}
+		contient := chemin == "" || rel == chemin || strings.HasPrefix(rel, chemin+"/")
+		if contient && len(chemin) > meilleure {
+			meilleure = len(chemin)
+			droits = regle.Permissions
+		}
+	}
+	return droits
+}
+
+// autorise vérifie, avant l'exécution de la requête req, que l'utilisateur de la session possède
+// la permission qu'elle requiert sur chacune de ses cibles (premiers arguments une fois ses options retirées,
+// voir Command.Targets et Command.Options), ou sur le dossier courant si la requête n'en a pas. Si la requête agit sur toute l'arborescence
+// de ses cibles (voir Command.Subtree), la permission est aussi exigée dans chaque règle de dossier qu'elles contiennent.
+// Chaque refus est consigné dans le journal d'audit. Sans comptes configurés, tout est autorisé.
+func (e *etatLogin) autorise(fsys *vfs, commande Command, req p.Request, remote string) bool {
+	perm := commande.Permission()
+	if perm == "" || e.user == nil {
+		return true
+	}
+
+	var args = sansOptions(req.Args, commande.Options())
+	var cibles = args[:min(len(args), commande.Targets())]
+	if len(cibles) == 0 {
+		cibles = []string{"."}
+	}
+
+	for _, cible := range cibles {
+		rel, err := fsys.relatif(cible)
+		if err != nil {
+			// Un nom qui sort de la racine sera refusé par la commande elle-même
+			continue
+		}
+		var chemins = []string{rel}
+		if commande.Subtree(req) {
+			chemins = append(chemins, e.user.reglesDans(rel)...)
+		}
+		for _, chemin := range chemins {
+			if !possede(e.user.permissions(chemin), perm) {
+				audit.Printf("refus : utilisateur=%s adresse=%s commande=%q cible=%q permission=%s",
+					e.user.Name, remote, req.String(), chemin, perm)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sansOptions retourne args sans les options connues de la commande ni les valeurs qui les suivent
+// ("tree -depth 2 -json" n'a pas de cible : le dossier courant est vérifié).
+func sansOptions(args []string, options map[string]int) []string {
+	var reste []string
+	for i := 0; i < len(args); i++ {
+		if n, option := options[args[i]]; option {
+			i += n
+			continue
+		}
+		reste = append(reste, args[i])
+	}
+	return reste
+}
+
+// reglesDans retourne les chemins des règles de dossier de l'utilisateur situées sous rel (rel exclu) :
+// supprimer ou déplacer rel touche aussi ces sous-arborescences, qui peuvent avoir des droits plus restreints.
+func (u *User) reglesDans(rel string) []string {
+	var chemins []string
+	for _, regle := range u.Rules {
+		chemin := strings.Trim(regle.Path, "/")
+		if chemin != "" && chemin != "." && (rel == "" || strings.HasPrefix(chemin, rel+"/")) {
+			chemins = append(chemins, chemin)
+		}
+	}
+	return chemins
+}
+
+// peutLire indique si l'utilisateur de la session peut lire le chemin rel (relatif à son dossier),
+// pour les commandes qui parcourent une arborescence au-delà de leur cible (TREE). Sans comptes configurés, tout est lisible.
+func (e *etatLogin) peutLire(rel string) bool {
+	return e.user == nil || possede(e.user.permissions(rel), PermRead)
+}
+
+// possede indique si perm fait partie de droits.
+func possede(droits []Permission, perm Permission) bool {
+	for _, droit := range droits {
+		if droit == perm {
+			return true
+		}
+	}
+	return false
+}
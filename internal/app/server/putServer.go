@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// ecritureTolerante écrit dans le fichier temporaire d'un PUT.
+// Après une erreur disque, les octets suivants sont ignorés : le flux est tout de même lu
+// en entier pour que le protocole reste synchronisé, et l'erreur est signalée à la fin.
+type ecritureTolerante struct {
+	fichier *os.File
+	err     error
+}
+
+func (e *ecritureTolerante) Write(b []byte) (int, error) {
+	if e.err == nil {
+		_, e.err = e.fichier.Write(b)
+	}
+	return len(b), nil
+}
+
+// PutServer : implémentation de PUT.
+// req : PUT <filename> <taille> ou PUT <filename> <taille> -f
+// - Répond 553 si le nom ou la taille sont invalides, 551 si le fichier existe sans "-f".
+// - Sinon répond 150, reçoit exactement <taille> octets dans un fichier temporaire du dossier cible,
+// le renomme atomiquement à sa place, puis répond 200 (ou 451 en cas d'erreur disque).
+func PutServer(c *p.Conn, fsys *vfs, req p.Request) bool {
+	var ecraser = len(req.Args) == 3 && req.Args[2] == "-f"
+	size, errSize := strconv.ParseInt(req.Args[1], 10, 64)
+
+	path, rel, err := fsys.visible(req.Args[0])
+	if err != nil || rel == "" || errSize != nil || size < 0 || (len(req.Args) == 3 && !ecraser) || !estDossier(filepath.Dir(path)) {
+		log.Println("PUT refusé pour:", req.Args[0])
+		return envoyerReponsePut(c, p.CodeNameRefused, "Envoi refusé")
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if !info.Mode().IsRegular() {
+			log.Println("PUT refusé, la cible n'est pas un fichier:", rel)
+			return envoyerReponsePut(c, p.CodeNameRefused, "Envoi refusé")
+		}
+		if !ecraser {
+			log.Println("PUT refusé, le fichier existe déjà:", rel)
+			return envoyerReponsePut(c, p.CodeFileExists, "Le fichier existe déjà")
+		}
+	}
+
+	// Le fichier temporaire est caché (préfixe '.') et placé dans le dossier cible pour que le renommage soit atomique
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		log.Println("Ne peut pas créer le fichier temporaire :", err)
+		return envoyerReponsePut(c, p.CodeLocalError, "Échec de l'écriture sur le serveur")
+	}
+	defer func() {
+		// Sans effet si le fichier temporaire a déjà été renommé
+		if err := os.Remove(temp.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println("Erreur suppression du fichier temporaire:", err)
+		}
+	}()
+
+	if !envoyerReponsePut(c, p.CodeStart, "Prêt à recevoir") {
+		temp.Close()
+		return false
+	}
+
+	var destination = &ecritureTolerante{fichier: temp}
+	if err := c.ReceiveData(destination, size); err != nil {
+		temp.Close()
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de la réception du fichier PUT:", err)
+		}
+		log.Println("Erreur lors de la réception du fichier PUT:", err)
+		return false
+	}
+
+	err = destination.err
+	if err == nil {
+		err = temp.Chmod(0644)
+	}
+	if errClose := temp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		if ecraser {
+			err = os.Rename(temp.Name(), path)
+		} else {
+			// Link échoue si un autre client a créé le fichier entre-temps : rien n'est écrasé sans "-f"
+			err = os.Link(temp.Name(), path)
+			if errors.Is(err, os.ErrExist) {
+				log.Println("PUT refusé, le fichier a été créé entre-temps:", rel)
+				return envoyerReponsePut(c, p.CodeFileExists, "Le fichier existe déjà")
+			}
+		}
+	}
+	if err != nil {
+		log.Println("Erreur lors de l'écriture du fichier PUT:", err)
+		return envoyerReponsePut(c, p.CodeLocalError, "Échec de l'écriture sur le serveur")
+	}
+
+	log.Println("Fichier reçu:", rel, size, "octets")
+	return envoyerReponsePut(c, p.CodeOK, "Fichier reçu")
+}
+
+// envoyerReponsePut envoie une réponse de la commande PUT et retourne false en cas d'erreur réseau.
+func envoyerReponsePut(c *p.Conn, code p.Code, texte string) bool {
+	if err := c.SendResponse(code, texte); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la réponse PUT", code, ":", err)
+		}
+		return false
+	}
+	return true
+}
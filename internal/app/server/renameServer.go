@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// RenameServer : renomme ou déplace un fichier ou un dossier, sans sortir de la racine servie.
+// req : RENAME <source> <destination> ; si la destination est un dossier existant, la source y est déplacée
+// sous son nom actuel. Rien n'est écrasé.
+// Répond 200 si succès, 550 si la source est introuvable, 551 si la destination existe déjà,
+// 553 si la destination est refusée (dossier parent absent, nom caché, dossier déplacé dans lui-même),
+// 451 si le renommage échoue.
+func RenameServer(c *p.Conn, fsys *vfs, req p.Request) bool {
+	source, relSource, err := fsys.visible(req.Args[0])
+	if err == nil && relSource != "" {
+		_, err = os.Lstat(source)
+	}
+	if err != nil || relSource == "" {
+		log.Println("RENAME : source non trouvée:", req.Args[0])
+		return repondre(c, p.CodeFileUnknown, "Source introuvable", "RENAME")
+	}
+
+	var nomDestination = req.Args[1]
+	if cible, _, err := fsys.visible(nomDestination); err == nil {
+		if info, err := os.Lstat(cible); err == nil && info.IsDir() {
+			nomDestination = path.Join(nomDestination, path.Base(relSource))
+		}
+	}
+	destination, relDestination, err := fsys.visible(nomDestination)
+	if err != nil || relDestination == "" || !estDossier(filepath.Dir(destination)) ||
+		relDestination == relSource || strings.HasPrefix(relDestination, relSource+"/") {
+		log.Println("RENAME refusé pour la destination:", req.Args[1])
+		return repondre(c, p.CodeNameRefused, "Destination refusée", "RENAME")
+	}
+	if _, err := os.Lstat(destination); !errors.Is(err, fs.ErrNotExist) {
+		log.Println("RENAME refusé, la destination existe déjà:", relDestination)
+		return repondre(c, p.CodeFileExists, "La destination existe déjà", "RENAME")
+	}
+
+	if err := os.Rename(source, destination); err != nil {
+		log.Println("Ne peut pas rename le fichier :", err)
+		return repondre(c, p.CodeLocalError, "Échec du renommage sur le serveur", "RENAME")
+	}
+	log.Println("Renommé:", relSource, "->", relDestination)
+	// Les éléments cachés d'un dossier déplacé le restent à leur nouvel emplacement
+	if err := fsys.caches().deplacer(fsys.dansRegistre(relSource), fsys.dansRegistre(relDestination)); err != nil {
+		log.Println("Ne peut pas enregistrer le registre des fichiers cachés :", err)
+	}
+	return repondre(c, p.CodeOK, "Renommé en "+relDestination, "RENAME")
+}
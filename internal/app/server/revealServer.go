@@ -1,58 +1,56 @@
 package server
 
 import (
-	"bufio"
 	"errors"
+	"io/fs"
 	"log"
 	"net"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
-// REVEAL : retire le prefixe '.' pour rendre visible le fichier.
-func REVEAL(conn net.Conn, commHideReveal []string, writer *bufio.Writer) bool {
-	var fichiers, err = os.ReadDir(commHideReveal[2]) // recupere le repertoire
-	if err != nil {
-		log.Println("Erreur lecture dossier :", err)
-		return false
-	}
+// REVEAL : retire un fichier ou un dossier du registre des éléments cachés pour le rendre visible.
+// Un élément absent du registre mais dont le nom commence par '.' (caché par une ancienne version du serveur,
+// qui le renommait ".<nom>") est révélé en retirant ce point ; il peut être désigné par "<nom>" ou ".<nom>".
+// Répond 200 si succès, 550 si le fichier n'est pas caché, 551 si un élément visible porte déjà le nom sans point,
+// 451 si le registre ne peut pas être enregistré ou le renommage échoue.
+func REVEAL(c *p.Conn, fsys *vfs, req p.Request) bool {
+	rel, err := fsys.relatif(req.Args[0]) // chemin depuis le dossier courant, le fichier n'a pas besoin d'exister
 	var found = false
-
-	for _, fichier := range fichiers { // trouver le fichier dans le repertoire
-		if commHideReveal[1] == fichier.Name() { // fichier trouvé
-			found = true
-			log.Println("Fichier trouvé:", fichier.Name())
-			var oldPath = filepath.Join(commHideReveal[2], fichier.Name())
-			var newPath = filepath.Join(commHideReveal[2], strings.TrimPrefix(fichier.Name(), ".")) // enleve le "." en tête du fichier
-
-			err := os.Rename(oldPath, newPath)
-			if err != nil {
-				log.Println("Ne peut pas rename le fichier :", err)
-				return false
-			}
-			log.Println("Le fichier a bien été REVEAL")
-
-			if err := p.Send_message(conn, writer, "OK"); err != nil {
-				var netErr net.Error
-				if errors.As(err, &netErr) && netErr.Timeout() {
-					log.Println("Timeout lors de l'envoi de 'OK' REVEAL:", err)
-				}
-				return false
-			}
-			break
+	if err == nil && rel != "" {
+		found, err = fsys.caches().reveler(fsys.dansRegistre(rel))
+		if err == nil && !found {
+			found, err = revelerParPoint(fsys, rel)
 		}
 	}
+	if errors.Is(err, fs.ErrExist) {
+		log.Println("REVEAL refusé, un élément visible porte déjà ce nom:", rel)
+		return repondre(c, p.CodeFileExists, "Un élément visible porte déjà ce nom", "REVEAL")
+	}
+	if err != nil && !errors.Is(err, ErrHorsRacine) {
+		log.Println("Ne peut pas révéler le fichier (registre ou renommage) :", err)
+		return repondre(c, p.CodeLocalError, "Échec de l'enregistrement sur le serveur", "REVEAL")
+	}
+
+	if found { // fichier trouvé
+		log.Println("Le fichier a bien été REVEAL:", rel)
 
-	// gestion du fileUnknown
-	if !found {
-		log.Println("Fichier non trouvé:", commHideReveal[1])
-		if err := p.Send_message(conn, writer, "FileUnknown"); err != nil {
+		if err := c.SendResponse(p.CodeOK, "Fichier révélé"); err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Println("Timeout lors de l'envoi de 'OK' REVEAL:", err)
+			}
+			return false
+		}
+	} else { // gestion du fileUnknown
+		log.Println("Fichier non trouvé (ou pas caché):", req.Args[0])
+		if err := c.SendResponse(p.CodeFileUnknown, "Fichier introuvable"); err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de l'envoi de 'FileUnknown' REVEAL:", err)
+				log.Println("Timeout lors de l'envoi du refus REVEAL:", err)
 			}
 			return false
 		}
@@ -60,3 +58,28 @@ func REVEAL(conn net.Conn, commHideReveal []string, writer *bufio.Writer) bool {
 
 	return true
 }
+
+// revelerParPoint révèle l'élément caché par son nom ".<nom>" désigné par rel ("<nom>" ou ".<nom>") en le renommant "<nom>".
+// Retourne false si cet élément n'existe pas, fs.ErrExist si "<nom>" existe déjà.
+func revelerParPoint(fsys *vfs, rel string) (bool, error) {
+	dossier, nom := path.Split(rel)
+	nom = strings.TrimPrefix(nom, ".")
+	if nom == "" || strings.HasPrefix(nom, ".") || fsys.interne(dossier+"."+nom) {
+		return false, nil
+	}
+
+	var cache, visible = fsys.surDisque(dossier + "." + nom), fsys.surDisque(dossier + nom)
+	if err := fsys.dansLaRacine(cache); err != nil {
+		return false, err
+	}
+	if _, err := os.Lstat(cache); err != nil {
+		return false, nil
+	}
+	if _, err := os.Lstat(visible); !errors.Is(err, fs.ErrNotExist) {
+		return false, fs.ErrExist
+	}
+	if err := os.Rename(cache, visible); err != nil {
+		return false, err
+	}
+	return true, nil
+}
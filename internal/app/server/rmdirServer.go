@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// OptionRecursive : dernier argument de RMDIR supprimant le dossier avec tout son contenu.
+const OptionRecursive = "-r"
+
+// RmdirServer : supprime un dossier vide, ou avec tout son contenu (éléments cachés compris) avec -r.
+// Le dossier courant de la session et ses dossiers parents ne peuvent pas être supprimés.
+// Répond 200 si succès, 550 si le dossier est introuvable, 552 s'il n'est pas vide (sans -r),
+// 553 si la cible n'est pas un dossier ou contient le dossier courant, 451 si la suppression échoue.
+func RmdirServer(c *p.Conn, fsys *vfs, req p.Request) bool {
+	args, recursif := p.CutOption(req.Args, OptionRecursive)
+	if len(args) != 1 {
+		return repondre(c, p.CodeSyntaxError, "Usage : RMDIR <dir> [-r]", "RMDIR")
+	}
+
+	path, rel, err := fsys.visible(args[0])
+	var info os.FileInfo
+	if err == nil && rel != "" {
+		info, err = os.Lstat(path)
+	}
+	if err != nil || rel == "" {
+		log.Println("RMDIR : dossier non trouvé:", args[0])
+		return repondre(c, p.CodeFileUnknown, "Dossier introuvable", "RMDIR")
+	}
+	if !info.IsDir() {
+		log.Println("RMDIR refusé, la cible n'est pas un dossier:", rel)
+		return repondre(c, p.CodeNameRefused, "La cible n'est pas un dossier, utiliser DELETE", "RMDIR")
+	}
+	if fsys.cwd == rel || strings.HasPrefix(fsys.cwd, rel+"/") {
+		log.Println("RMDIR refusé, le dossier contient le dossier courant:", rel)
+		return repondre(c, p.CodeNameRefused, "Impossible de supprimer le dossier courant ou un de ses parents", "RMDIR")
+	}
+
+	if recursif {
+		err = os.RemoveAll(path)
+	} else {
+		vide, errLecture := dossierVide(path)
+		if errLecture == nil && !vide {
+			log.Println("RMDIR refusé, le dossier n'est pas vide:", rel)
+			return repondre(c, p.CodeNotEmpty, "Le dossier n'est pas vide (éléments cachés compris), utiliser RMDIR -r", "RMDIR")
+		}
+		err = errLecture
+		if err == nil {
+			err = os.Remove(path)
+		}
+	}
+	if err != nil {
+		log.Println("Ne peut pas supprimer le dossier :", err)
+		return repondre(c, p.CodeLocalError, "Échec de la suppression sur le serveur", "RMDIR")
+	}
+	log.Println("Dossier supprimé:", rel)
+	if err := fsys.caches().oublier(fsys.dansRegistre(rel)); err != nil {
+		log.Println("Ne peut pas enregistrer le registre des fichiers cachés :", err)
+	}
+	return repondre(c, p.CodeOK, "Dossier supprimé", "RMDIR")
+}
+
+// dossierVide indique si le dossier réel dossier ne contient aucun élément, caché ou non.
+func dossierVide(dossier string) (bool, error) {
+	f, err := ouvrirDossier(dossier)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var premier []os.DirEntry
+	premier, err = f.ReadDir(1)
+	if len(premier) > 0 {
+		return false, nil
+	}
+	if errors.Is(err, io.EOF) {
+		return true, nil
+	}
+	return false, err
+}
@@ -1,12 +1,13 @@
 package server
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"log/slog"
 	"net"
-	"sync"
+	"os"
 	"time"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
@@ -15,28 +16,83 @@ import (
 // connectiontime : moment où le serveur normal a commencé à écouter
 var connectiontime time.Time
 
-// WaitGroup pour attendre la fin des deux serveurs (normal + contrôle)
-var serverWg sync.WaitGroup
-
 // RunServer lance deux listeners concurrents : un server "normal" et un server "control"
-func RunServer(port *string, controlPort *string) {
+// cfg est partagée en lecture seule par tous les handlers.
+// L'annulation de ctx (signal reçu par le programme) arrête le serveur comme TERMINATE.
+// RunServer retourne une fois le serveur arrêté (TERMINATE, ctx, ou échec d'un listener) et toutes les sessions terminées.
+// L'erreur retournée est non nil si le serveur n'a pas pu démarrer (configuration invalide) ou si un listener a échoué.
+func RunServer(ctx context.Context, cfg *Config) error {
+
+	// La racine servie doit exister avant d'accepter des clients
+	info, err := os.Stat(cfg.Root)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("dossier servi invalide : %s", cfg.Root)
+	}
+	log.Println("Dossier servi :", cfg.Root)
+
+	if err := chargerCaches(cfg.Root); err != nil {
+		return fmt.Errorf("dossier servi invalide : %w", err)
+	}
+
+	if cfg.AuditFile != "" {
+		if err := ouvrirAudit(cfg.AuditFile); err != nil {
+			return err
+		}
+	}
+
+	if cfg.UsersFile != "" {
+		comptes, err = loadUsers(cfg.UsersFile, cfg.Root)
+		if err != nil {
+			return err
+		}
+		log.Println("Authentification activée :", len(comptes.users), "comptes")
+	}
+
+	// Délai de chaque lecture ou écriture pendant un échange ; l'attente des commandes suit cfg.IdleTimeout
+	if cfg.TransferTimeout > 0 {
+		p.MessageTimeout = time.Duration(cfg.TransferTimeout) * time.Second
+	}
+
+	normalTLS, controlTLS, err := tlsConfigs(cfg)
+	if err != nil {
+		return err
+	}
+	if normalTLS != nil {
+		log.Println("TLS activé sur les deux ports, mTLS sur le port de contrôle :", cfg.ControlMTLS)
+	}
+
+	a := nouvelArret()
+	definirArret(a)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Println("Arrêt demandé par le programme :", context.Cause(ctx))
+			a.arreter()
+		case <-a.fini:
+		}
+	}()
 
-	serverWg.Add(2)
-	go runNormalServer(port)
-	go runControlServer(controlPort)
+	a.listeners.Add(2)
+	go runNormalServer(cfg, normalTLS, a)
+	go runControlServer(cfg, controlTLS, a)
 
-	// Attendre que les deux serveurs se terminent (appelé après shutdown).
-	serverWg.Wait()
+	// Attendre le début de l'arrêt, puis la fin des sessions et des deux listeners
+	a.drainer(time.Duration(cfg.DrainTimeout) * time.Second)
+	a.listeners.Wait()
 	log.Println("Tous les serveurs sont arrêtés")
+	return a.erreur
 }
 
 // Listener principal pour les clients pas admins
-func runNormalServer(port *string) {
-	defer serverWg.Done()
+// tlsConfig est nil si le serveur écoute en clair.
+func runNormalServer(cfg *Config, tlsConfig *tls.Config, a *arret) {
+	defer a.listeners.Done()
+	port := cfg.Port
 
-	l, err := net.Listen("tcp", ":"+*port)
+	l, err := listen(port, tlsConfig)
 	if err != nil {
-		slog.Error(err.Error())
+		a.echouer(err)
 		return
 	}
 	// enregistrer le temps de début pour l'uptime
@@ -48,14 +104,14 @@ func runNormalServer(port *string) {
 		if err != nil {
 			return
 		}
-		slog.Debug("Stopped listening on port " + *port)
+		slog.Debug("Stopped listening on port " + port)
 	}()
-	slog.Debug("Now listening on port " + *port)
+	slog.Debug("Now listening on port " + port)
 
-	// Goroutine qui ferme le listener lorsque shutdownChan est fermé
+	// Goroutine qui ferme le listener au début de l'arrêt
 	// Cela permet à l'Accept() bloquant de sortir avec une erreur contrôlable
 	go func() {
-		<-shutdownChan
+		<-a.ctx.Done()
 		err := l.Close()
 		if err != nil {
 			return
@@ -66,7 +122,7 @@ func runNormalServer(port *string) {
 		c, err := l.Accept()
 		if err != nil {
 			// On est en cours d'arrêt, on termine proprement la boucle
-			if isServerShuttingDown() {
+			if a.ctx.Err() != nil {
 				slog.Info("Server normal terminé, arrêt des nouvelles connexions")
 				return
 			}
@@ -74,18 +130,29 @@ func runNormalServer(port *string) {
 			slog.Error(err.Error())
 			continue
 		}
-		slog.Info("Incoming connection from " + c.RemoteAddr().String() + " on port " + *port)
-		go HandleClient(c)
+		slog.Info("Incoming connection from " + c.RemoteAddr().String() + " on port " + port)
+
+		// Limites de connexions : le client est prévenu avant la fermeture, sans démarrer de session
+		ip := adresseIP(c.RemoteAddr())
+		if ok, motif := connexions.ouvrir(ip, cfg.MaxSessions, cfg.MaxSessionsPerIP); !ok {
+			go refuserConnexion(c, motif)
+			continue
+		}
+		go func() {
+			defer connexions.fermer(ip)
+			HandleClient(c, cfg)
+		}()
 	}
 }
 
 // Listener pour le port de contrôle
-func runControlServer(controlPort *string) {
-	defer serverWg.Done()
+func runControlServer(cfg *Config, tlsConfig *tls.Config, a *arret) {
+	defer a.listeners.Done()
+	controlPort := cfg.ControlPort
 
-	l, err := net.Listen("tcp", ":"+*controlPort)
+	l, err := listen(controlPort, tlsConfig)
 	if err != nil {
-		slog.Error(err.Error())
+		a.echouer(err)
 		return
 	}
 
@@ -94,13 +161,13 @@ func runControlServer(controlPort *string) {
 		if err != nil {
 			return
 		}
-		slog.Debug("Stopped listening on port " + *controlPort)
+		slog.Debug("Stopped listening on port " + controlPort)
 	}()
-	slog.Debug("Now listening on port " + *controlPort)
+	slog.Debug("Now listening on port " + controlPort)
 
-	// Même mécanisme de fermeture via shutdownChan
+	// Même mécanisme de fermeture au début de l'arrêt
 	go func() {
-		<-shutdownChan
+		<-a.ctx.Done()
 		err := l.Close()
 		if err != nil {
 			return
@@ -110,7 +177,7 @@ func runControlServer(controlPort *string) {
 	for {
 		c, err := l.Accept()
 		if err != nil {
-			if isServerShuttingDown() {
+			if a.ctx.Err() != nil {
 				slog.Info("Server de contrôle terminé, arrêt des nouvelles connexions")
 				return
 			}
@@ -118,8 +185,20 @@ func runControlServer(controlPort *string) {
 			continue
 		}
 		slog.Info("Incoming connection from " + c.RemoteAddr().String())
-		go HandleControlClient(c)
+		go HandleControlClient(c, cfg)
+	}
+}
+
+// listen ouvre un listener TCP sur port, chiffré si tlsConfig n'est pas nil.
+func listen(port string, tlsConfig *tls.Config) (net.Listener, error) {
+	l, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		return tls.NewListener(l, tlsConfig), nil
 	}
+	return l, nil
 }
 
 // ClientLogOut : décrémente le compteur client et ferme la connexion
@@ -136,7 +215,7 @@ func ClientLogOut(conn net.Conn) {
 }
 
 // DebugServer : envoie des informations de debug au client
-func DebugServer(conn net.Conn, writer *bufio.Writer) bool {
+func DebugServer(c *p.Conn) bool {
 	msg := fmt.Sprintf("DebugInfo: clients=%d, operations=%d, uptime=%s",
 		getCompteurClient(),
 		getCompteurOperations(),
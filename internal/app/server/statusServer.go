@@ -0,0 +1,20 @@
+package server
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// StatusServer : état du serveur (commande disponible sur le port de contrôle).
+// Répond "211 operations=<N> uptime=<durée> sessions=<N> maxSessions=<N> ..." : opérations en cours,
+// temps écoulé depuis l'ouverture du port normal, puis sessions ouvertes et limites de connexions (voir compteConnexions.etat).
+func StatusServer(c *p.Conn, cfg *Config) bool {
+	var etat = "operations=" + strconv.Itoa(getCompteurOperations()) +
+		" uptime=" + time.Since(connectiontime).Truncate(time.Second).String() +
+		" " + connexions.etat(cfg)
+	log.Println("État du serveur :", etat)
+	return repondre(c, p.CodeStatus, etat, "STATUS")
+}
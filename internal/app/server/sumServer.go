@@ -0,0 +1,97 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// progressionEmpreinte : intervalle des messages "110" envoyés pendant le calcul d'une empreinte,
+// pour que le client, qui attend la réponse, ne dépasse pas son délai (proto.MessageTimeout).
+const progressionEmpreinte = time.Second
+
+// empreinte : résultat d'un calcul d'empreinte SHA-256 fait en arrière-plan.
+type empreinte struct {
+	somme string
+	err   error
+}
+
+// calculerEmpreinte calcule en arrière-plan l'empreinte SHA-256 (en hexadécimal) du contenu de source.
+// Le canal est tamponné : le calcul se termine même si personne n'attend son résultat.
+func calculerEmpreinte(source io.Reader) <-chan empreinte {
+	resultat := make(chan empreinte, 1)
+	go func() {
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, source); err != nil {
+			resultat <- empreinte{err: err}
+			return
+		}
+		resultat <- empreinte{somme: hex.EncodeToString(hasher.Sum(nil))}
+	}()
+	return resultat
+}
+
+// attendreEmpreinte attend le résultat d'un calcul lancé par calculerEmpreinte, en envoyant "110" au client
+// toutes les progressionEmpreinte. ok vaut false en cas d'erreur réseau.
+func attendreEmpreinte(c *p.Conn, resultat <-chan empreinte, commande string) (e empreinte, ok bool) {
+	var debut = time.Now()
+	ticker := time.NewTicker(progressionEmpreinte)
+	defer ticker.Stop()
+	for {
+		select {
+		case e = <-resultat:
+			return e, true
+		case <-ticker.C:
+			var duree = strconv.Itoa(int(time.Since(debut).Seconds()))
+			if !repondre(c, p.CodeInProgress, "Calcul de l'empreinte en cours ("+duree+" s)", commande) {
+				return e, false
+			}
+		}
+	}
+}
+
+// SumServer : implémentation de SUM.
+// Répond "213 <sha256>" avec l'empreinte du fichier demandé, ou "550" s'il n'existe pas.
+// Pendant le calcul d'un gros fichier, "110" est envoyé chaque seconde avant la réponse.
+func SumServer(c *p.Conn, fsys *vfs, req p.Request) bool {
+	var code, texte = p.CodeFileUnknown, "Fichier introuvable"
+
+	path, rel, err := fsys.visible(req.Args[0])
+	if err == nil {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			fichier, err := os.Open(path)
+			if err != nil {
+				log.Println("Ne peut pas ouvrir le fichier :", err)
+			} else {
+				e, ok := attendreEmpreinte(c, calculerEmpreinte(fichier), "SUM")
+				fichier.Close()
+				if !ok {
+					return false
+				}
+				if e.err != nil {
+					log.Println("Erreur lors du calcul de l'empreinte:", e.err)
+				} else {
+					log.Println("Empreinte de", rel, ":", e.somme)
+					code, texte = p.CodeChecksum, e.somme
+				}
+			}
+		}
+	}
+
+	if err := c.SendResponse(code, texte); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Println("Timeout lors de l'envoi de la réponse SUM:", err)
+		}
+		return false
+	}
+	return true
+}
@@ -1,11 +1,9 @@
 package server
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"log"
-	"net"
-	"os"
 	"sync"
 	"time"
 
@@ -21,27 +19,6 @@ var (
 	compteurMutex      sync.Mutex
 )
 
-// Canal de signal de terminaison : on le ferme pour indiquer à toutes les goroutines de bloquer de nouvelles connexions.
-// shutdownOnce évite de fermer deux fois.
-var shutdownChan = make(chan struct{})
-var shutdownOnce sync.Once
-
-// Booléen et mutex pour indiquer si le serveur est en train de se terminer.
-var terminaisonDuServeur = false
-var terminaisonMutex sync.RWMutex
-
-// ClientIO : stocke reader/writer du client de contrôle qui a demandé TERMINATE.
-// On garde ces informations pour pouvoir envoyer des messages d'état pendant la terminaison.
-type ClientIO struct {
-	writer *bufio.Writer
-	reader *bufio.Reader
-}
-
-var (
-	clientTerminant      ClientIO
-	clientTerminantMutex sync.Mutex
-)
-
 // fonctions pour manipuler les compteurs.
 func incrementerClient() int {
 	compteurMutex.Lock()
@@ -78,65 +55,205 @@ func getCompteurClient() int {
 	return compteurClient
 }
 
-// Accès au flag de terminaison du serveur.
-func isServerShuttingDown() bool {
-	terminaisonMutex.RLock()
-	defer terminaisonMutex.RUnlock()
-	return terminaisonDuServeur
+// messageArret : réponse 421 envoyée aux clients déconnectés par l'arrêt du serveur.
+const messageArret = "Server terminating, connection closing."
+
+// arret : coordination de l'arrêt d'une exécution du serveur (RunServer), partagée par les listeners et les sessions.
+// L'arrêt commence quand ctx est annulé (TERMINATE) : les listeners se ferment, les sessions inactives
+// sont déconnectées, et celles qui exécutent une commande la terminent avant d'être déconnectées.
+// Au-delà du délai de drainage, les sessions restantes sont prévenues et fermées de force.
+type arret struct {
+	ctx     context.Context
+	annuler context.CancelFunc
+
+	listeners sync.WaitGroup // listeners en cours d'exécution
+	sessions  sync.WaitGroup // sessions inscrites, attendues avant la fin de l'arrêt
+
+	mu        sync.Mutex
+	actives   map[*session]bool // sessions inscrites, pour les déconnecter
+	demandeur *p.Conn           // client de contrôle qui a demandé TERMINATE, nil si aucun
+	fini      chan struct{}     // fermé quand toutes les sessions sont terminées
+	erreur    error             // échec d'un listener qui a provoqué l'arrêt, retourné par RunServer
 }
-func setServerShuttingDown() {
-	terminaisonMutex.Lock()
-	defer terminaisonMutex.Unlock()
-	terminaisonDuServeur = true
+
+// nouvelArret prépare l'arrêt d'une exécution du serveur.
+func nouvelArret() *arret {
+	ctx, annuler := context.WithCancel(context.Background())
+	return &arret{ctx: ctx, annuler: annuler, actives: make(map[*session]bool), fini: make(chan struct{})}
 }
 
-// TerminateServer : procédure de terminaison du serveur
-func TerminateServer(conn net.Conn) {
-	log.Println("Initiation de la terminaison du serveur...")
-	setServerShuttingDown() // Indique que le serveur s'arrête
+// arretServeur : arrêt de l'exécution en cours, remplacé à chaque appel de RunServer.
+var (
+	arretServeur = nouvelArret()
+	arretMutex   sync.Mutex
+)
 
-	clientTerminantMutex.Lock()
-	writer := clientTerminant.writer
-	clientTerminantMutex.Unlock()
+func arretCourant() *arret {
+	arretMutex.Lock()
+	defer arretMutex.Unlock()
+	return arretServeur
+}
+func definirArret(a *arret) {
+	arretMutex.Lock()
+	defer arretMutex.Unlock()
+	arretServeur = a
+}
 
-	// Boucle d'attente : on surveille opérations et clients.
-	for {
-		ops := getCompteurOperations()
-		clients := getCompteurClient()
+// inscrire ajoute une session à attendre lors de l'arrêt ; false si l'arrêt a déjà commencé.
+func (a *arret) inscrire(s *session) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ctx.Err() != nil {
+		return false
+	}
+	a.actives[s] = true
+	a.sessions.Add(1)
+	return true
+}
 
-		// Soustraire le client de contrôle qui a initié la commande
-		clientsApresControle := clients - 1
+// desinscrire retire une session terminée (sans effet si elle a déjà été retirée).
+func (a *arret) desinscrire(s *session) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.actives[s] {
+		delete(a.actives, s)
+		a.sessions.Done()
+	}
+}
 
-		// Condition de sortie : aucune opération en cours et aucun client (hors control).
-		if ops == 0 && clientsApresControle == 0 {
-			break
-		}
+// demander commence l'arrêt à la demande de la session s (TERMINATE) : elle n'est pas attendue
+// et reçoit l'avancement de l'arrêt. false si l'arrêt a déjà commencé.
+func (a *arret) demander(s *session) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ctx.Err() != nil {
+		return false
+	}
+	if a.actives[s] {
+		delete(a.actives, s)
+		a.sessions.Done()
+	}
+	a.demandeur = s.conn
+	a.annuler()
+	return true
+}
+
+// arreter commence l'arrêt sans client de contrôle à informer.
+func (a *arret) arreter() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.annuler()
+}
+
+// echouer commence l'arrêt après l'échec d'un listener ; la première erreur est retournée par RunServer.
+func (a *arret) echouer(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.erreur == nil {
+		a.erreur = err
+	}
+	a.annuler()
+}
+
+// attendreCommande marque la session inactive avant la lecture d'une commande ; false si l'arrêt a commencé.
+func (a *arret) attendreCommande(s *session) bool {
+	s.etat.Lock()
+	defer s.etat.Unlock()
+	s.occupee = false
+	return a.ctx.Err() == nil
+}
 
-		msg := fmt.Sprintf("Opérations en cours : %d, Clients actifs (hors contrôle) : %d. Attente...", ops, clientsApresControle)
-		log.Println(msg)
-		if err := p.Send_message(conn, writer, msg); err != nil {
-			log.Println("Erreur lors de l'envoi du message d'attente de terminaison:", err)
+// commencerCommande marque la session occupée après la lecture d'une commande ; false si l'arrêt a commencé
+// (la session a alors peut-être déjà été déconnectée).
+func (a *arret) commencerCommande(s *session) bool {
+	s.etat.Lock()
+	defer s.etat.Unlock()
+	s.occupee = true
+	return a.ctx.Err() == nil
+}
+
+// deconnecter prévient et ferme les sessions restantes ; si inactivesSeulement, celles qui exécutent
+// une commande sont laissées terminer.
+func (a *arret) deconnecter(inactivesSeulement bool) int {
+	a.mu.Lock()
+	var sessions = make([]*session, 0, len(a.actives))
+	for s := range a.actives {
+		sessions = append(sessions, s)
+	}
+	a.mu.Unlock()
+
+	var nombre int
+	for _, s := range sessions {
+		s.etat.Lock()
+		if !inactivesSeulement || !s.occupee {
+			// La session ne peut plus recevoir de commande : sa lecture échoue et elle se termine
+			if err := s.conn.Interrupt(p.CodeShuttingDown, messageArret); err != nil {
+				log.Println("Erreur lors de la déconnexion de", s.conn.RemoteAddr().String(), ":", err)
+			}
+			nombre++
 		}
+		s.etat.Unlock()
+	}
+	return nombre
+}
 
-		time.Sleep(1 * time.Second)
+// informer envoie un message d'avancement au client de contrôle qui a demandé l'arrêt, s'il y en a un.
+func (a *arret) informer(code p.Code, msg string) {
+	a.mu.Lock()
+	c := a.demandeur
+	a.mu.Unlock()
+	if c == nil {
+		return
 	}
+	if err := c.SendResponse(code, msg); err != nil {
+		log.Println("Erreur lors de l'envoi du message de terminaison:", err)
+	}
+}
 
-	finalMsg := "Terminaison finie, le serveur s'éteint"
-	log.Println(finalMsg)
+// drainer attend le début de l'arrêt puis la fin de toutes les sessions.
+// delai est le temps laissé aux commandes en cours (0 pour l'attendre indéfiniment).
+func (a *arret) drainer(delai time.Duration) {
+	<-a.ctx.Done()
+	log.Println("Initiation de la terminaison du serveur...")
+	if n := a.deconnecter(true); n > 0 {
+		log.Println("Sessions inactives déconnectées :", n)
+	}
+
+	termine := make(chan struct{})
+	go func() {
+		a.sessions.Wait()
+		close(termine)
+	}()
 
-	if err := p.Send_message(conn, writer, finalMsg); err != nil {
-		log.Println("Erreur lors de l'envoi du message final de terminaison:", err)
+	var limite <-chan time.Time
+	if delai > 0 {
+		timer := time.NewTimer(delai)
+		defer timer.Stop()
+		limite = timer.C
 	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
-	// Fermer shutdownChan pour signaler aux goroutines d'arrêter.
-	shutdownOnce.Do(func() {
-		close(shutdownChan)
-	})
+	for {
+		select {
+		case <-termine:
+			finalMsg := "Terminaison finie, le serveur s'éteint"
+			log.Println(finalMsg)
+			a.informer(p.CodeBye, finalMsg)
+			close(a.fini)
+			return
 
-	// ClientLogOut sera appelé par le defer de HandleControlClient après le retour.
-	log.Println("Terminaison complète, fermeture du serveur...")
+		case <-ticker.C:
+			a.mu.Lock()
+			sessions := len(a.actives)
+			a.mu.Unlock()
+			msg := fmt.Sprintf("Opérations en cours : %d, Clients actifs (hors contrôle) : %d. Attente...", getCompteurOperations(), sessions)
+			log.Println(msg)
+			a.informer(p.CodeInProgress, msg)
 
-	// On force une sortie après un court délai pour s'assurer de la fermeture.
-	time.Sleep(500 * time.Millisecond)
-	os.Exit(0)
+		case <-limite:
+			log.Println("Délai de terminaison dépassé, sessions fermées de force :", a.deconnecter(false))
+			limite = nil
+		}
+	}
 }
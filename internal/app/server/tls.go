@@ -0,0 +1,61 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// tlsConfigs prépare les configurations TLS des deux listeners à partir de cfg.
+// Sans certificat configuré, les deux valeurs sont nil et le serveur écoute en clair.
+// Avec ControlMTLS, le port de contrôle exige en plus un certificat client signé par CAFile.
+func tlsConfigs(cfg *Config) (normal *tls.Config, control *tls.Config, err error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		if cfg.ControlMTLS {
+			return nil, nil, errors.New("le mTLS du port de contrôle nécessite -cert et -key")
+		}
+		return nil, nil, nil
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, nil, errors.New("-cert et -key doivent être fournis ensemble")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("chargement du certificat serveur: %w", err)
+	}
+	normal = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	control = normal.Clone()
+	if cfg.ControlMTLS {
+		if cfg.CAFile == "" {
+			return nil, nil, errors.New("le mTLS du port de contrôle nécessite -ca")
+		}
+		pool, err := chargerCA(cfg.CAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		control.ClientCAs = pool
+		control.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return normal, control, nil
+}
+
+// chargerCA lit un fichier PEM de certificats d'autorité.
+func chargerCA(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("lecture du certificat d'autorité: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("aucun certificat valide dans %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/app/client"
+)
+
+// certificatTest : certificat généré pour les tests, enregistré en PEM dans certFile et keyFile.
+type certificatTest struct {
+	cert     *x509.Certificate
+	cle      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// nouveauCertificat génère le certificat nom d'après modele, signé par parent (auto-signé si parent est nil),
+// et l'enregistre dans dossier.
+func nouveauCertificat(t *testing.T, dossier, nom string, modele *x509.Certificate, parent *certificatTest) *certificatTest {
+	t.Helper()
+	cle, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serie, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modele.SerialNumber = serie
+	modele.Subject = pkix.Name{CommonName: nom}
+	modele.NotBefore = time.Now().Add(-time.Hour)
+	modele.NotAfter = time.Now().Add(time.Hour)
+
+	var signataire, cleSignataire = modele, cle
+	if parent != nil {
+		signataire, cleSignataire = parent.cert, parent.cle
+	}
+	der, err := x509.CreateCertificate(rand.Reader, modele, signataire, &cle.PublicKey, cleSignataire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	derCle, err := x509.MarshalECPrivateKey(cle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c = &certificatTest{
+		cert:     cert,
+		cle:      cle,
+		certFile: filepath.Join(dossier, nom+".pem"),
+		keyFile:  filepath.Join(dossier, nom+"-key.pem"),
+	}
+	ecrirePEM(t, c.certFile, "CERTIFICATE", der)
+	ecrirePEM(t, c.keyFile, "EC PRIVATE KEY", derCle)
+	return c
+}
+
+func ecrirePEM(t *testing.T, path, genre string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: genre, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// autorite : modèle d'un certificat d'autorité.
+func autorite() *x509.Certificate {
+	return &x509.Certificate{IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}
+}
+
+// feuille : modèle d'un certificat serveur ou client.
+func feuille(usage x509.ExtKeyUsage) *x509.Certificate {
+	return &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:    []string{"localhost"},
+	}
+}
+
+// echangerTLS accepte une connexion sur l, dont le serveur termine la négociation TLS puis envoie une ligne,
+// et s'y connecte avec clientConfig. Retourne l'erreur vue par chaque côté.
+func echangerTLS(t *testing.T, l net.Listener, clientConfig *tls.Config) (errServeur, errClient error) {
+	t.Helper()
+	resultat := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			resultat <- err
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		err = conn.(*tls.Conn).Handshake()
+		if err == nil {
+			_, err = conn.Write([]byte("220 ok\n"))
+		}
+		resultat <- err
+	}()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	conn, errClient := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", "127.0.0.1:"+port, clientConfig)
+	if errClient == nil {
+		// En TLS 1.3, le refus du certificat client n'arrive qu'à la première lecture
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		var ligne string
+		ligne, errClient = bufio.NewReader(conn).ReadString('\n')
+		if errClient == nil && ligne != "220 ok\n" {
+			t.Fatalf("ligne reçue %q, attendu \"220 ok\"", ligne)
+		}
+		conn.Close()
+	}
+	return <-resultat, errClient
+}
+
+func TestTLS(t *testing.T) {
+	var dossier = t.TempDir()
+	ca := nouveauCertificat(t, dossier, "ca", autorite(), nil)
+	serveur := nouveauCertificat(t, dossier, "serveur", feuille(x509.ExtKeyUsageServerAuth), ca)
+	clientCA := nouveauCertificat(t, dossier, "client", feuille(x509.ExtKeyUsageClientAuth), ca)
+	autreCA := nouveauCertificat(t, dossier, "autre-ca", autorite(), nil)
+	clientAutreCA := nouveauCertificat(t, dossier, "client-autre", feuille(x509.ExtKeyUsageClientAuth), autreCA)
+
+	var cfg = &Config{
+		CertFile:    serveur.certFile,
+		KeyFile:     serveur.keyFile,
+		CAFile:      ca.certFile,
+		ControlMTLS: true,
+	}
+	normalTLS, controlTLS, err := tlsConfigs(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normal, err := listen("0", normalTLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer normal.Close()
+	controle, err := listen("0", controlTLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer controle.Close()
+
+	var cas = []struct {
+		nom     string
+		port    net.Listener
+		cert    *certificatTest // certificat présenté par le client, nil si aucun
+		accepte bool
+	}{
+		{nom: "port normal sans certificat client", port: normal, accepte: true},
+		{nom: "port normal avec certificat client", port: normal, cert: clientCA, accepte: true},
+		{nom: "port de contrôle avec certificat de la CA", port: controle, cert: clientCA, accepte: true},
+		{nom: "port de contrôle sans certificat client", port: controle, accepte: false},
+		{nom: "port de contrôle avec certificat d'une autre CA", port: controle, cert: clientAutreCA, accepte: false},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			var certFile, keyFile string
+			if c.cert != nil {
+				certFile, keyFile = c.cert.certFile, c.cert.keyFile
+			}
+			clientConfig, err := client.NewTLSConfig(ca.certFile, certFile, keyFile, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			errServeur, errClient := echangerTLS(t, c.port, clientConfig)
+			if c.accepte && (errServeur != nil || errClient != nil) {
+				t.Fatalf("connexion refusée : serveur %v, client %v", errServeur, errClient)
+			}
+			if !c.accepte && (errServeur == nil || errClient == nil) {
+				t.Fatalf("connexion acceptée : serveur %v, client %v", errServeur, errClient)
+			}
+		})
+	}
+}
+
+func TestTLSConfigsInvalides(t *testing.T) {
+	var dossier = t.TempDir()
+	ca := nouveauCertificat(t, dossier, "ca", autorite(), nil)
+	serveur := nouveauCertificat(t, dossier, "serveur", feuille(x509.ExtKeyUsageServerAuth), ca)
+
+	var cas = []struct {
+		nom string
+		cfg Config
+	}{
+		{nom: "mTLS sans certificat serveur", cfg: Config{ControlMTLS: true, CAFile: ca.certFile}},
+		{nom: "certificat sans clé", cfg: Config{CertFile: serveur.certFile}},
+		{nom: "mTLS sans CA", cfg: Config{CertFile: serveur.certFile, KeyFile: serveur.keyFile, ControlMTLS: true}},
+		{nom: "clé d'un autre certificat", cfg: Config{CertFile: serveur.certFile, KeyFile: ca.keyFile}},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			if _, _, err := tlsConfigs(&c.cfg); err == nil {
+				t.Fatal("configuration acceptée, erreur attendue")
+			}
+		})
+	}
+
+	// Sans certificat ni mTLS, le serveur écoute en clair
+	normal, controle, err := tlsConfigs(&Config{})
+	if normal != nil || controle != nil || err != nil {
+		t.Fatalf("tlsConfigs(vide) = %v, %v, %v ; attendu nil, nil, nil", normal, controle, err)
+	}
+}
@@ -1,99 +1,159 @@
 package server
 
 import (
-	"bufio"
 	"errors"
 	"log"
+	"math"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
-	"strings"
 
 	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
 )
 
-// ParcourFolder : fonction récursive utilisée par tree pour construire l'arborescence.
-// Retourne la liste sous forme de chaîne et le nombre total d'éléments trouvés.
-// Remarque : gère les erreurs en les loggant, et continue sur sous-dossiers problématiques.
-func ParcourFolder(fichiers []os.DirEntry, list string, size int) (string, int) {
-	for _, fichier := range fichiers {
+// limiteArbre : limites d'un parcours TREE, partagées par tous les niveaux de la récursion.
+type limiteArbre struct {
+	profondeur int  // nombre de niveaux listés (1 = contenu du dossier de départ), 0 pour illimité
+	restant    int  // nombre d'éléments pouvant encore être ajoutés à la liste
+	tronque    bool // des éléments ont été omis parce que restant est tombé à 0
+}
+
+// prendre réserve la place d'un élément ; false si le nombre maximal d'éléments est atteint.
+func (l *limiteArbre) prendre() bool {
+	if l.restant <= 0 {
+		l.tronque = true
+		return false
+	}
+	l.restant--
+	return true
+}
+
+// descendre indique si les sous-dossiers trouvés au niveau niveau doivent être parcourus.
+func (l *limiteArbre) descendre(niveau int) bool {
+	return l.profondeur == 0 || niveau < l.profondeur
+}
+
+// ParcourFolder : fonction récursive utilisée par tree pour envoyer l'arborescence au fil du parcours.
+// dossier est le dossier réel ouvert, relDepart le chemin relatif à la racine du dossier de départ,
+// prefixe le chemin affiché des éléments de dossier ("" au départ, "docs/" ensuite),
+// niveau leur profondeur (1 pour le contenu du dossier de départ).
+// Envoie chaque élément visible, un sous-dossier ("docs/") étant suivi de son contenu, dans la limite de limite ;
+// en JSON, les éléments cachés sont inclus si avecCaches. Un sous-dossier que l'utilisateur de login
+// n'a pas le droit de lire (voir RegleDossier) est envoyé sans son contenu.
+// Remarque : gère les erreurs de lecture en les loggant, et continue sur sous-dossiers problématiques.
+func ParcourFolder(fsys *vfs, login *etatLogin, dossier *os.File, relDepart string, prefixe string, flux *fluxListe, enJSON bool, avecCaches bool, limite *limiteArbre, niveau int) error {
+	return parcourirDossier(dossier, func(fichier os.DirEntry) error {
+		var nom = prefixe + p.NormalizeName(fichier.Name())
+		var rel = path.Join(relDepart, nom)
+		var cache = fsys.cache(rel)
+		if (cache && !(enJSON && avecCaches)) || !nomTransmissible(fichier.Name()) || fsys.interne(rel) {
+			return nil
+		}
 		fileInfo, err := fichier.Info()
 		if err != nil {
 			log.Println("Erreur lors de la lecture du fichier:", err)
-			return err.Error(), 0
+			return nil
 		}
-		size = size + 1
-		if fichier.Name()[0] != '.' {
-			if fichier.IsDir() {
-				var newfichiers, err = os.ReadDir(filepath.Join("Docs/", fichier.Name()))
-				if err != nil {
-					log.Println("Erreur lecture sous-dossier:", err)
-					continue
-				}
-				var liste, newsize = ParcourFolder(newfichiers, list, size)
-				size = size + newsize
-				list = list + " --" + fichier.Name() + " " + strconv.FormatInt(fileInfo.Size(), 10) + " -- sous-dossier: " + " [" + liste + "]"
-			} else {
-				list = list + " --" + fichier.Name() + " " + strconv.FormatInt(fileInfo.Size(), 10)
-			}
+		if !limite.prendre() {
+			return errArretParcours
 		}
-	}
-	return list, size
+
+		if enJSON {
+			var element = decrire(fileInfo)
+			element.Name = nom
+			element.Depth = niveau
+			element.Hidden = cache
+			err = flux.envoyerJSON(element)
+		} else if fichier.IsDir() {
+			err = flux.envoyer(p.FormatEntry(p.Entry{Name: nom + "/", Size: fileInfo.Size()}))
+		} else {
+			err = flux.envoyer(p.FormatEntry(p.Entry{Name: nom, Size: fileInfo.Size()}))
+		}
+		if err != nil || !fichier.IsDir() || !limite.descendre(niveau) {
+			return err
+		}
+		if !login.peutLire(rel) {
+			log.Println("TREE : lecture refusée, dossier non parcouru :", rel)
+			return nil
+		}
+
+		sousDossier, err := ouvrirDossier(filepath.Join(dossier.Name(), fichier.Name()))
+		if err != nil {
+			log.Println("Erreur lecture sous-dossier:", err)
+			return nil
+		}
+		defer sousDossier.Close()
+		err = ParcourFolder(fsys, login, sousDossier, relDepart, nom+"/", flux, enJSON, avecCaches, limite, niveau+1)
+		var netErr net.Error
+		if err != nil && !errors.Is(err, errArretParcours) && !errors.As(err, &netErr) {
+			log.Println("Erreur lecture sous-dossier:", err)
+			return nil
+		}
+		return err
+	})
 }
 
-// tree : construit et envoie l'arbre complet du dossier "Docs".
-// Protocole similaire à LIST : Start -> attendre OK -> envoyer la liste complète.
-func tree(conn net.Conn, writer *bufio.Writer, reader *bufio.Reader) bool {
+// OptionProfondeur : option de TREE suivie du nombre de niveaux parcourus.
+const OptionProfondeur = "-depth"
 
-	//Lecture du fichier à la racine
-	var fichiers, err = os.ReadDir("Docs")
-	if err != nil {
-		log.Println("Erreur lecture dossier Docs:", err)
-		return false
+// tree : envoie l'arbre du dossier courant de la session.
+// Protocole similaire à LIST : Start -> attendre OK -> un élément "151" par fichier ou dossier -> "226 <N>".
+// TREE -depth N limite le parcours à N niveaux ; au-delà de maxElements éléments, le parcours s'arrête
+// et "111" est envoyé avant la fin de la liste.
+// TREE -json envoie un objet JSON proto.FileInfo par élément, comme LIST -json, Name étant le chemin relatif
+// et Depth la profondeur de l'élément (voir proto.FileInfo).
+func tree(c *p.Conn, fsys *vfs, login *etatLogin, req p.Request, caps p.Capabilities, avecCaches bool, maxElements int) bool {
+	args, enJSON := p.CutOption(req.Args, p.OptionJSON)
+	if enJSON && !caps.Has(p.FeatureJSON) {
+		return refuserListe(c, p.CodeOptionRefused, "Option -json non négociée", "TREE")
 	}
 
-	var list = ""
-	var size = 0
-
-	//Envoit du message pour commencer
-	if err := p.Send_message(conn, writer, "Start"); err != nil {
-		var netErr net.Error
-		if errors.As(err, &netErr) && netErr.Timeout() {
-			log.Println("Timeout lors de l'envoi de 'Start' (tree):", err)
+	var limite = &limiteArbre{restant: maxElements}
+	if maxElements <= 0 {
+		limite.restant = math.MaxInt
+	}
+	if len(args) == 2 && args[0] == OptionProfondeur {
+		profondeur, err := strconv.Atoi(args[1])
+		if err != nil || profondeur < 1 {
+			return refuserListe(c, p.CodeSyntaxError, "Profondeur invalide : "+args[1], "TREE")
 		}
-		return false
+		limite.profondeur = profondeur
+	} else if len(args) > 0 {
+		return refuserListe(c, p.CodeSyntaxError, "Usage : TREE [-depth N] [-json]", "TREE")
 	}
 
-	//Reception reponse du client
-	data, err := p.Receive_message(conn, reader)
+	//Ouverture du dossier courant de la session
+	var f *os.File
+	reel, rel, err := fsys.visible(".")
+	if err == nil {
+		f, err = ouvrirDossier(reel)
+	}
 	if err != nil {
-		var netErr net.Error
-		if errors.As(err, &netErr) && netErr.Timeout() {
-			log.Println("Timeout lors de la réception de la confirmation 'OK' (tree):", err)
-		}
+		log.Println("Erreur lecture du dossier courant:", fsys.position(), err)
+		return refuserListe(c, p.CodeFileUnknown, "Dossier introuvable", "TREE")
+	}
+	defer f.Close()
+
+	//Envoi du message pour commencer et réception du OK du client
+	var flux = &fluxListe{c: c, commande: "TREE"}
+	if !flux.commencer("Arborescence à suivre") {
 		return false
 	}
 
-	//Si le message est ok alors début du parcours
-	if strings.TrimSpace(data) == "OK" {
-		var templist, tempsize = ParcourFolder(fichiers, list, size)
-		log.Println("list : ", tempsize, templist)
-		list = list + templist
-		size = tempsize
-		var newlist = "FileCnt : " + strconv.Itoa(size) + list
+	//Parcours : chaque élément est envoyé dès qu'il est lu
+	err = ParcourFolder(fsys, login, f, rel, "", flux, enJSON, avecCaches, limite, 1)
 
-		if err := p.Send_message(conn, writer, newlist); err != nil {
+	// Des éléments ont été omis : le client est prévenu avant la fin de la liste
+	if limite.tronque {
+		if err := flux.tronquer("Arborescence tronquée à " + strconv.Itoa(maxElements) + " éléments"); err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
-				log.Println("Timeout lors de l'envoi de la liste finale (tree):", err)
+				log.Println("Timeout lors de l'envoi de l'avertissement de troncature (tree):", err)
 			}
 			return false
 		}
-	} else {
-		log.Println("Protocole tree échoué : Attendu 'OK', reçu:", strings.TrimSpace(data))
-		return false
 	}
-
-	return true
+	return flux.terminer(err)
 }
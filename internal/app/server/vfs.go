@@ -0,0 +1,216 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	p "gitlab.univ-nantes.fr/iutna.info2.r305/proj/internal/pkg/proto"
+)
+
+// ErrHorsRacine est renvoyée quand un nom demandé par le client sort de la racine servie
+// (".." au-delà de la racine, chemin absolu ou lien symbolique pointant ailleurs).
+var ErrHorsRacine = errors.New("chemin hors de la racine servie")
+
+// vfs : système de fichiers virtuel propre à une session.
+// Le client n'envoie que des noms relatifs à son dossier courant : c'est le serveur qui
+// conserve ce dossier courant et qui confine toute résolution à la racine servie.
+type vfs struct {
+	nom  string // nom affiché de la racine (ex : "Docs")
+	root string // chemin réel absolu de la racine, liens symboliques résolus
+	cwd  string // dossier courant relatif à la racine, séparé par '/' ("" = racine)
+}
+
+// newVFS prépare le système de fichiers virtuel d'une session, positionné à la racine.
+func newVFS(racine string) (*vfs, error) {
+	abs, err := filepath.Abs(racine)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	return &vfs{nom: filepath.Base(abs), root: root}, nil
+}
+
+// changerRacine replace la session à la racine d'un autre dossier servi (ex : dossier d'un utilisateur).
+func (v *vfs) changerRacine(racine string) error {
+	nouveau, err := newVFS(racine)
+	if err != nil {
+		return err
+	}
+	*v = *nouveau
+	return nil
+}
+
+// position retourne le dossier courant tel qu'il est affiché au client (ex : "Docs/docs").
+func (v *vfs) position() string {
+	if v.cwd == "" {
+		return v.nom
+	}
+	return v.nom + "/" + v.cwd
+}
+
+// relatif calcule le chemin, relatif à la racine, désigné par name depuis le dossier courant.
+// Les chemins absolus et les ".." qui remontent au-dessus de la racine sont refusés.
+// Le nom est normalisé en NFC (voir proto.NormalizeName).
+func (v *vfs) relatif(name string) (string, error) {
+	if name == "" || path.IsAbs(name) {
+		return "", ErrHorsRacine
+	}
+	joined := path.Join(v.cwd, p.NormalizeName(name))
+	if joined == ".." || strings.HasPrefix(joined, "../") {
+		return "", ErrHorsRacine
+	}
+	if joined == "." {
+		return "", nil
+	}
+	return joined, nil
+}
+
+// resolve convertit un nom envoyé par le client en chemin réel sous la racine.
+// Le chemin n'a pas besoin d'exister, mais ses liens symboliques ne doivent pas sortir de la racine.
+func (v *vfs) resolve(name string) (string, error) {
+	rel, err := v.relatif(name)
+	if err != nil {
+		return "", err
+	}
+	real := v.surDisque(rel)
+	if err := v.dansLaRacine(real); err != nil {
+		return "", err
+	}
+	return real, nil
+}
+
+// surDisque construit le chemin réel de rel (normalisé en NFC).
+// Un élément enregistré sur le disque sous une autre forme Unicode (NFD, fichier créé sous macOS par exemple)
+// est retrouvé en comparant la forme normalisée des noms du dossier parent.
+func (v *vfs) surDisque(rel string) string {
+	real := v.root
+	if rel == "" {
+		return real
+	}
+	for _, composant := range strings.Split(rel, "/") {
+		candidat := filepath.Join(real, composant)
+		if _, err := os.Lstat(candidat); errors.Is(err, fs.ErrNotExist) {
+			if entrees, err := os.ReadDir(real); err == nil {
+				for _, entree := range entrees {
+					if p.NormalizeName(entree.Name()) == composant {
+						candidat = filepath.Join(real, entree.Name())
+						break
+					}
+				}
+			}
+		}
+		real = candidat
+	}
+	return real
+}
+
+// dansLaRacine vérifie, après résolution des liens symboliques, que real reste sous la racine.
+// Si real n'existe pas encore, c'est son plus proche ancêtre existant qui est vérifié.
+func (v *vfs) dansLaRacine(real string) error {
+	existant := real
+	for {
+		resolu, err := filepath.EvalSymlinks(existant)
+		if err == nil {
+			rel, err := filepath.Rel(v.root, resolu)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return ErrHorsRacine
+			}
+			return nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		parent := filepath.Dir(existant)
+		if parent == existant {
+			return err
+		}
+		existant = parent
+	}
+}
+
+// caches retourne le registre des éléments cachés par HIDE, dont les chemins sont convertis par dansRegistre.
+func (v *vfs) caches() *registreCaches {
+	return caches
+}
+
+// dansRegistre convertit rel, relatif à la racine de la session, en chemin relatif à la racine servie,
+// celui du registre des éléments cachés (une session authentifiée peut être placée dans un sous-dossier).
+// Le dossier d'un utilisateur situé hors de la racine servie donne un chemin commençant par "..".
+func (v *vfs) dansRegistre(rel string) string {
+	base, err := filepath.Rel(caches.racine, v.root)
+	if err != nil {
+		return rel
+	}
+	if chemin := path.Join(filepath.ToSlash(base), rel); chemin != "." {
+		return chemin
+	}
+	return ""
+}
+
+// cache indique si le chemin relatif rel est caché : un de ses composants commence par '.',
+// ou lui-même ou un de ses dossiers parents a été caché par HIDE.
+func (v *vfs) cache(rel string) bool {
+	return estCache(rel) || v.caches().contient(v.dansRegistre(rel))
+}
+
+// interne indique si rel désigne le registre des éléments cachés (FichierCaches à la racine servie)
+// ou un de ses fichiers temporaires : métadonnées du serveur, jamais listées, même sur le port de contrôle.
+func (v *vfs) interne(rel string) bool {
+	chemin := v.dansRegistre(rel)
+	return chemin == FichierCaches || (strings.HasPrefix(chemin, FichierCaches+".") && strings.HasSuffix(chemin, ".tmp"))
+}
+
+// visible résout name comme resolve, mais refuse aussi les chemins qui passent par un élément caché.
+// Retourne le chemin réel et le chemin relatif à la racine.
+func (v *vfs) visible(name string) (string, string, error) {
+	rel, err := v.relatif(name)
+	if err != nil {
+		return "", "", err
+	}
+	if v.cache(rel) {
+		return "", "", fs.ErrNotExist
+	}
+	real, err := v.resolve(name)
+	if err != nil {
+		return "", "", err
+	}
+	return real, rel, nil
+}
+
+// chdir change le dossier courant de la session. La cible doit être un dossier visible.
+func (v *vfs) chdir(target string) error {
+	real, rel, err := v.visible(target)
+	if err != nil {
+		return err
+	}
+	if !estDossier(real) {
+		return fs.ErrNotExist
+	}
+	v.cwd = rel
+	return nil
+}
+
+// estCache indique si un des composants du chemin relatif commence par '.' (fichier ou dossier caché).
+// Un tel élément est toujours caché, qu'il figure ou non dans le registre : fichiers de configuration (".gitignore"),
+// registre du serveur, et fichiers cachés par les anciennes versions du serveur (voir REVEAL).
+func estCache(rel string) bool {
+	for _, composant := range strings.Split(rel, "/") {
+		if strings.HasPrefix(composant, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// estDossier indique si le chemin réel existe et désigne un dossier.
+func estDossier(real string) bool {
+	info, err := os.Stat(real)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// nouvelleRacine prépare une racine servie de test :
+//
+//	racine/docs/salut.txt
+//	racine/<"café" en NFD>.txt
+//	racine/interne -> racine/docs   (lien qui reste dans la racine)
+//	racine/dehors  -> autre dossier (lien qui sort de la racine)
+//
+// et retourne le vfs de la session, positionné à la racine, ainsi que le dossier extérieur.
+func nouvelleRacine(t *testing.T) (*vfs, string) {
+	t.Helper()
+	var racine = filepath.Join(t.TempDir(), "Docs")
+	var exterieur = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(racine, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, fichier := range []string{
+		filepath.Join(racine, "docs", "salut.txt"),
+		filepath.Join(racine, norm.NFD.String("café")+".txt"),
+		filepath.Join(exterieur, "secret.txt"),
+	} {
+		if err := os.WriteFile(fichier, []byte("contenu"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(racine, "docs"), filepath.Join(racine, "interne")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(exterieur, filepath.Join(racine, "dehors")); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := newVFS(racine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fsys, exterieur
+}
+
+func TestRelatif(t *testing.T) {
+	fsys, _ := nouvelleRacine(t)
+
+	var cas = []struct {
+		nom     string
+		cwd     string
+		demande string
+		attendu string
+		erreur  bool
+	}{
+		{nom: "fichier à la racine", demande: "salut.txt", attendu: "salut.txt"},
+		{nom: "racine elle-même", demande: ".", attendu: ""},
+		{nom: "sous-dossier", cwd: "docs", demande: "salut.txt", attendu: "docs/salut.txt"},
+		{nom: "remontée jusqu'à la racine", cwd: "docs", demande: "..", attendu: ""},
+		{nom: "remontée au-dessus de la racine", demande: "..", erreur: true},
+		{nom: "remontée déguisée", cwd: "docs", demande: "a/../../..", erreur: true},
+		{nom: "remontée puis redescente", demande: "../Docs/docs", erreur: true},
+		{nom: "chemin absolu", demande: "/etc/passwd", erreur: true},
+		{nom: "nom vide", demande: "", erreur: true},
+		{nom: "nom NFD normalisé en NFC", demande: norm.NFD.String("café.txt"), attendu: "café.txt"},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			fsys.cwd = c.cwd
+			rel, err := fsys.relatif(c.demande)
+			if c.erreur {
+				if !errors.Is(err, ErrHorsRacine) {
+					t.Fatalf("relatif(%q) = %q, %v ; attendu ErrHorsRacine", c.demande, rel, err)
+				}
+				return
+			}
+			if err != nil || rel != c.attendu {
+				t.Fatalf("relatif(%q) = %q, %v ; attendu %q", c.demande, rel, err, c.attendu)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	fsys, _ := nouvelleRacine(t)
+
+	var cas = []struct {
+		nom     string
+		demande string
+		attendu string // chemin réel relatif à la racine, séparé par '/'
+		erreur  bool
+	}{
+		{nom: "fichier existant", demande: "docs/salut.txt", attendu: "docs/salut.txt"},
+		{nom: "fichier absent", demande: "docs/nouveau.txt", attendu: "docs/nouveau.txt"},
+		{nom: "lien dans la racine", demande: "interne/salut.txt", attendu: "interne/salut.txt"},
+		{nom: "feuille absente sous un lien dans la racine", demande: "interne/absent.txt", attendu: "interne/absent.txt"},
+		{nom: "lien qui sort de la racine", demande: "dehors", erreur: true},
+		{nom: "fichier sous un lien qui sort", demande: "dehors/secret.txt", erreur: true},
+		{nom: "feuille absente sous un lien qui sort", demande: "dehors/absent.txt", erreur: true},
+		{nom: "remontée au-dessus de la racine", demande: "../secret.txt", erreur: true},
+		{nom: "chemin absolu", demande: "/etc/passwd", erreur: true},
+		{nom: "nom NFC d'un fichier enregistré en NFD", demande: "café.txt", attendu: norm.NFD.String("café") + ".txt"},
+		{nom: "nom NFD d'un fichier enregistré en NFD", demande: norm.NFD.String("café.txt"), attendu: norm.NFD.String("café") + ".txt"},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			reel, err := fsys.resolve(c.demande)
+			if c.erreur {
+				if !errors.Is(err, ErrHorsRacine) {
+					t.Fatalf("resolve(%q) = %q, %v ; attendu ErrHorsRacine", c.demande, reel, err)
+				}
+				return
+			}
+			var attendu = filepath.Join(fsys.root, filepath.FromSlash(c.attendu))
+			if err != nil || reel != attendu {
+				t.Fatalf("resolve(%q) = %q, %v ; attendu %q", c.demande, reel, err, attendu)
+			}
+		})
+	}
+}
+
+func TestDansLaRacine(t *testing.T) {
+	fsys, exterieur := nouvelleRacine(t)
+
+	var cas = []struct {
+		nom    string
+		reel   string
+		erreur bool
+	}{
+		{nom: "racine", reel: fsys.root},
+		{nom: "fichier existant", reel: filepath.Join(fsys.root, "docs", "salut.txt")},
+		{nom: "fichier absent", reel: filepath.Join(fsys.root, "docs", "absent.txt")},
+		{nom: "dossiers absents", reel: filepath.Join(fsys.root, "a", "b", "c.txt")},
+		{nom: "feuille absente sous un lien dans la racine", reel: filepath.Join(fsys.root, "interne", "absent.txt")},
+		{nom: "lien qui sort de la racine", reel: filepath.Join(fsys.root, "dehors"), erreur: true},
+		{nom: "feuille absente sous un lien qui sort", reel: filepath.Join(fsys.root, "dehors", "a", "absent.txt"), erreur: true},
+		{nom: "dossier extérieur", reel: exterieur, erreur: true},
+		{nom: "parent de la racine", reel: filepath.Dir(fsys.root), erreur: true},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			err := fsys.dansLaRacine(c.reel)
+			if c.erreur && !errors.Is(err, ErrHorsRacine) {
+				t.Fatalf("dansLaRacine(%q) = %v ; attendu ErrHorsRacine", c.reel, err)
+			}
+			if !c.erreur && err != nil {
+				t.Fatalf("dansLaRacine(%q) = %v ; attendu nil", c.reel, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package proto
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Code : code numérique placé en tête de chaque réponse du serveur ("<code> <texte>"), dans l'esprit de FTP.
+// Le client décide d'après le code ; le texte est destiné à l'utilisateur (ou porte la donnée de la réponse).
+//   - 1xx : réponse préliminaire, d'autres lignes vont suivre
+//   - 2xx : succès
+//   - 3xx : le serveur attend une information supplémentaire
+//   - 4xx : échec temporaire, la commande peut être retentée
+//   - 5xx : échec définitif
+type Code int
+
+const (
+	CodeInProgress Code = 110 // opération longue en cours (attente pendant TERMINATE)
+	CodeTruncated  Code = 111 // liste tronquée (nombre maximal d'éléments atteint), la liste partielle suit
+	CodeStart      Code = 150 // données à suivre (liste, fichier) ou serveur prêt à recevoir (PUT)
+	CodeEntry      Code = 151 // un élément d'une liste envoyée en flux (LIST, TREE, MLSD), d'autres suivent
+
+	CodeOK       Code = 200 // commande exécutée
+	CodeStatus   Code = 211 // état du serveur (STATUS) : sessions ouvertes et limites de connexions
+	CodeChecksum Code = 213 // empreinte SHA-256 d'un fichier (SUM, fin de GET)
+	CodeHelp     Code = 214 // liste des commandes disponibles
+	CodeHello    Code = 220 // accueil : version, fonctionnalités et position de départ
+	CodeBye      Code = 221 // fin de session ou arrêt du serveur terminé
+	CodeListing  Code = 226 // fin d'une liste (LIST, TREE, MLSD), suivi du nombre d'éléments
+	CodeLoggedIn Code = 230 // authentification réussie, suivi de la position de départ
+	CodeMoved    Code = 250 // GOTO réussi, suivi de la nouvelle position
+
+	CodeAuthRequired Code = 330 // le serveur exige un login USER/PASS
+	CodePassRequired Code = 331 // nom reçu, mot de passe attendu
+
+	CodeControlBusy  Code = 420 // port de contrôle occupé par une autre session (ou session reprise), connexion fermée
+	CodeShuttingDown Code = 421 // serveur en cours d'arrêt, connexion fermée
+	CodeExpired      Code = 422 // session expirée (inactivité ou durée maximale atteinte), connexion fermée
+	CodeTooManyConns Code = 429 // limite de connexions atteinte (serveur, adresse IP ou utilisateur), connexion fermée
+	CodeLocked       Code = 430 // compte temporairement bloqué
+	CodeLocalError   Code = 451 // erreur du serveur pendant le traitement (ex : écriture d'un PUT)
+
+	CodeUnknownCommand   Code = 500 // commande inconnue
+	CodeSyntaxError      Code = 501 // requête mal formée (guillemet non fermé, nombre d'arguments invalide...)
+	CodeBadSequence      Code = 503 // commande inattendue à ce stade de la session (ex : USER après le login)
+	CodeOptionRefused    Code = 504 // option non supportée ou fonctionnalité non négociée (ex : -json)
+	CodeVersionRefused   Code = 505 // aucune version ou fonctionnalité obligatoire commune, connexion fermée
+	CodeLoginRequired    Code = 530 // commande refusée avant authentification
+	CodeLoginFailed      Code = 531 // identifiants invalides
+	CodeAdminRequired    Code = 532 // port de contrôle réservé au rôle admin
+	CodePermissionDenied Code = 533 // permission insuffisante pour cette commande
+	CodeFileUnknown      Code = 550 // fichier ou dossier introuvable (ou inaccessible)
+	CodeFileExists       Code = 551 // le fichier existe déjà
+	CodeNotEmpty         Code = 552 // dossier non vide (RMDIR sans -r)
+	CodeNameRefused      Code = 553 // nom de fichier refusé
+	CodeBadOffset        Code = 554 // position de reprise invalide
+)
+
+// Reply formate une réponse "<code> <texte>".
+func Reply(code Code, texte string) string {
+	return strconv.Itoa(int(code)) + " " + texte
+}
+
+// ParseReply sépare le code et le texte d'une réponse.
+// code vaut 0 si la ligne ne commence pas par un code à trois chiffres.
+func ParseReply(msg string) (code Code, texte string) {
+	msg = strings.TrimSpace(msg)
+	champ, texte, _ := strings.Cut(msg, " ")
+	n, err := strconv.Atoi(champ)
+	if err != nil || len(champ) != 3 {
+		return 0, msg
+	}
+	return Code(n), texte
+}
@@ -0,0 +1,259 @@
+package proto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrSyntax : ligne de requête mal formée (guillemet non fermé, requête vide, texte non UTF-8...).
+// La connexion reste utilisable : seule la requête est rejetée.
+var ErrSyntax = errors.New("erreur de syntaxe")
+
+// Request : commande envoyée par le client, avec ses arguments.
+// Sur le fil, les arguments contenant des espaces, des guillemets ou des caractères de contrôle
+// sont placés entre guillemets doubles (voir Quote), ce qui permet des noms de fichiers avec espaces.
+type Request struct {
+	Command string
+	Args    []string
+}
+
+// NewRequest construit une requête à partir de la commande et de ses arguments.
+func NewRequest(command string, args ...string) Request {
+	return Request{Command: command, Args: args}
+}
+
+// String formate la requête telle qu'elle est envoyée sur le fil.
+func (r Request) String() string {
+	var champs = []string{r.Command}
+	for _, arg := range r.Args {
+		champs = append(champs, Quote(arg))
+	}
+	return strings.Join(champs, " ")
+}
+
+// ParseRequest lit une ligne de requête. La ligne doit être en UTF-8.
+func ParseRequest(line string) (Request, error) {
+	if !utf8.ValidString(line) {
+		return Request{}, fmt.Errorf("%w : requête non UTF-8", ErrSyntax)
+	}
+	champs, err := SplitArgs(line)
+	if err != nil {
+		return Request{}, err
+	}
+	if len(champs) == 0 {
+		return Request{}, fmt.Errorf("%w : requête vide", ErrSyntax)
+	}
+	return Request{Command: champs[0], Args: champs[1:]}, nil
+}
+
+// Response : réponse du serveur, "<code> <texte>".
+// Le texte est le reste de la ligne, sans guillemets : il porte un message ou la donnée de la réponse.
+type Response struct {
+	Code Code
+	Text string
+}
+
+// Quote retourne arg tel qu'il doit apparaître dans une requête :
+// inchangé s'il ne contient ni espace, ni guillemet, ni antislash, ni caractère de contrôle,
+// sinon entre guillemets doubles avec \" \\ \n \r \t échappés.
+func Quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \"\\\t\r\n") {
+		return arg
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range arg {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// NormalizeName met un nom de fichier sous sa forme Unicode composée (NFC).
+// Un même nom peut arriver décomposé (NFD, ex : "é" saisi sous macOS) : les deux côtés
+// comparent et envoient les noms sous la forme NFC.
+func NormalizeName(name string) string {
+	return norm.NFC.String(name)
+}
+
+// SplitArgs découpe une ligne en arguments séparés par des espaces, en tenant compte des guillemets (voir Quote).
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	var runes = []rune(strings.TrimRight(line, "\r\n"))
+
+	for i := 0; i < len(runes); {
+		if runes[i] == ' ' || runes[i] == '\t' {
+			i++
+			continue
+		}
+
+		var arg strings.Builder
+		if runes[i] != '"' {
+			for i < len(runes) && runes[i] != ' ' && runes[i] != '\t' {
+				if runes[i] == '"' {
+					return nil, fmt.Errorf("%w : guillemet au milieu d'un argument", ErrSyntax)
+				}
+				arg.WriteRune(runes[i])
+				i++
+			}
+			args = append(args, arg.String())
+			continue
+		}
+
+		// Argument entre guillemets
+		i++
+		var ferme = false
+		for i < len(runes) && !ferme {
+			switch {
+			case runes[i] == '"':
+				ferme = true
+			case runes[i] == '\\' && i+1 < len(runes):
+				i++
+				switch runes[i] {
+				case 'n':
+					arg.WriteRune('\n')
+				case 'r':
+					arg.WriteRune('\r')
+				case 't':
+					arg.WriteRune('\t')
+				default:
+					arg.WriteRune(runes[i])
+				}
+			default:
+				arg.WriteRune(runes[i])
+			}
+			i++
+		}
+		if !ferme {
+			return nil, fmt.Errorf("%w : guillemet non fermé", ErrSyntax)
+		}
+		if i < len(runes) && runes[i] != ' ' && runes[i] != '\t' {
+			return nil, fmt.Errorf("%w : caractère inattendu après un guillemet fermant", ErrSyntax)
+		}
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// Conn : connexion du protocole. Elle encadre l'envoi et la réception des requêtes, des réponses
+// et des blocs de données brutes, avec les timeouts de MessageTimeout (ou celui de WaitRequest).
+// Les lectures passent toutes par le même tampon : aucun octet reçu n'est perdu entre une ligne et des données.
+// Les envois sont sérialisés : Interrupt peut être appelée depuis une autre goroutine que celle de la session.
+type Conn struct {
+	net.Conn
+	reader   *bufio.Reader
+	writer   *bufio.Writer
+	ecriture sync.Mutex // envoi en cours
+}
+
+// NewConn enveloppe la connexion réseau conn.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+}
+
+// SendRequest envoie une requête, arguments entre guillemets si nécessaire.
+func (c *Conn) SendRequest(req Request) error {
+	c.ecriture.Lock()
+	defer c.ecriture.Unlock()
+	return Send_message(c.Conn, c.writer, req.String())
+}
+
+// ReceiveRequest reçoit une requête. Une ligne mal formée donne une erreur ErrSyntax
+// (la connexion reste utilisable) ; toute autre erreur vient du réseau.
+func (c *Conn) ReceiveRequest() (Request, error) {
+	line, err := Receive_message(c.Conn, c.reader)
+	if err != nil {
+		return Request{}, err
+	}
+	return ParseRequest(line)
+}
+
+// WaitRequest attend la prochaine requête entre deux échanges, en au plus timeout (0 : pas de limite) :
+// contrairement à ReceiveRequest, le délai porte sur l'inactivité du client et non sur un échange en cours.
+func (c *Conn) WaitRequest(timeout time.Duration) (Request, error) {
+	line, err := receiveMessageWithin(c.Conn, c.reader, timeout)
+	if err != nil {
+		return Request{}, err
+	}
+	return ParseRequest(line)
+}
+
+// SendResponse envoie la réponse "<code> <texte>".
+func (c *Conn) SendResponse(code Code, text string) error {
+	// Un retour à la ligne couperait la réponse en deux
+	text = strings.NewReplacer("\r", " ", "\n", " ").Replace(text)
+	c.ecriture.Lock()
+	defer c.ecriture.Unlock()
+	return Send_message(c.Conn, c.writer, Reply(code, text))
+}
+
+// ReceiveResponse reçoit une réponse du serveur ; erreur si la ligne ne commence pas par un code.
+func (c *Conn) ReceiveResponse() (Response, error) {
+	line, err := Receive_message(c.Conn, c.reader)
+	if err != nil {
+		return Response{}, err
+	}
+	code, text := ParseReply(line)
+	if code == 0 {
+		return Response{Text: text}, fmt.Errorf("réponse sans code : %q", text)
+	}
+	return Response{Code: code, Text: text}, nil
+}
+
+// SendData envoie un bloc de exactement size octets lus depuis src.
+func (c *Conn) SendData(src io.Reader, size int64) error {
+	c.ecriture.Lock()
+	defer c.ecriture.Unlock()
+	return Send_data(c.Conn, c.writer, src, size)
+}
+
+// ReceiveData reçoit un bloc de exactement size octets et l'écrit dans dst.
+func (c *Conn) ReceiveData(dst io.Writer, size int64) error {
+	return Receive_data(c.Conn, c.reader, dst, size)
+}
+
+// interruptTimeout : délai d'envoi de la dernière réponse d'Interrupt, pour ne pas attendre un client bloqué.
+const interruptTimeout = time.Second
+
+// Interrupt envoie une dernière réponse, si aucun envoi n'est en cours sur la connexion, puis la ferme.
+// Elle est appelée depuis une autre goroutine que celle de la session (arrêt du serveur) : un envoi en cours
+// (fichier, liste) n'est pas coupé par une ligne parasite, la connexion est seulement fermée.
+func (c *Conn) Interrupt(code Code, text string) error {
+	var err error
+	if c.ecriture.TryLock() {
+		err = c.Conn.SetWriteDeadline(time.Now().Add(interruptTimeout))
+		if err == nil {
+			_, err = c.writer.WriteString(Reply(code, text) + "\n")
+		}
+		if err == nil {
+			err = c.writer.Flush()
+		}
+		LogMessage("sent", Reply(code, text))
+		c.ecriture.Unlock()
+	}
+	if errClose := c.Conn.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
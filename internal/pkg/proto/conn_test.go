@@ -0,0 +1,112 @@
+package proto
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestQuoteSplitArgs(t *testing.T) {
+	var cas = []struct {
+		nom    string
+		arg    string
+		guille bool // Quote doit mettre l'argument entre guillemets
+	}{
+		{nom: "nom simple", arg: "salut.txt"},
+		{nom: "espace", arg: "rapport final.pdf", guille: true},
+		{nom: "espaces en tête et en fin", arg: "  a  ", guille: true},
+		{nom: "guillemet", arg: `dit "bonjour".txt`, guille: true},
+		{nom: "guillemet seul", arg: `"`, guille: true},
+		{nom: "antislash", arg: `C:\dossier\fichier`, guille: true},
+		{nom: "antislash final", arg: `fin\`, guille: true},
+		{nom: "retour à la ligne", arg: "ligne1\nligne2", guille: true},
+		{nom: "tabulation", arg: "a\tb", guille: true},
+		{nom: "retour chariot", arg: "a\rb", guille: true},
+		{nom: "échappements littéraux", arg: `\n\t`, guille: true},
+		{nom: "argument vide", arg: "", guille: true},
+		{nom: "double tiret", arg: "--"},
+		{nom: "option", arg: "-json"},
+		{nom: "nom NFD", arg: norm.NFD.String("café.txt")},
+		{nom: "nom NFD avec espace", arg: norm.NFD.String("été 2025.txt"), guille: true},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			var q = Quote(c.arg)
+			if guille := q != c.arg; guille != c.guille {
+				t.Fatalf("Quote(%q) = %q ; guillemets attendus : %v", c.arg, q, c.guille)
+			}
+
+			// L'argument est relu à l'identique, seul ou entouré d'autres arguments
+			args, err := SplitArgs("GET " + q + " -- " + q + "\r\n")
+			if err != nil {
+				t.Fatalf("SplitArgs(%q) : %v", q, err)
+			}
+			if attendu := []string{"GET", c.arg, "--", c.arg}; !slices.Equal(args, attendu) {
+				t.Fatalf("SplitArgs(%q) = %q ; attendu %q", q, args, attendu)
+			}
+
+			req, err := ParseRequest(NewRequest("PUT", c.arg, "12", "-f").String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Command != "PUT" || !slices.Equal(req.Args, []string{c.arg, "12", "-f"}) {
+				t.Fatalf("ParseRequest(NewRequest(PUT, %q)) = %#v", c.arg, req)
+			}
+		})
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	var cas = []struct {
+		nom     string
+		ligne   string
+		attendu []string
+	}{
+		{nom: "ligne vide", ligne: "", attendu: nil},
+		{nom: "espaces et tabulations", ligne: " \t LIST \t docs  ", attendu: []string{"LIST", "docs"}},
+		{nom: "guillemets collés aux espaces", ligne: `RENAME "a b" "c d"`, attendu: []string{"RENAME", "a b", "c d"}},
+		{nom: "guillemets vides", ligne: `GET ""`, attendu: []string{"GET", ""}},
+		{nom: "échappement inconnu conservé", ligne: `GET "a\zb"`, attendu: []string{"GET", "azb"}},
+		{nom: "fin de ligne CRLF", ligne: "end\r\n", attendu: []string{"end"}},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			args, err := SplitArgs(c.ligne)
+			if err != nil || !slices.Equal(args, c.attendu) {
+				t.Fatalf("SplitArgs(%q) = %q, %v ; attendu %q", c.ligne, args, err, c.attendu)
+			}
+		})
+	}
+}
+
+func TestSplitArgsRejets(t *testing.T) {
+	var cas = []struct {
+		nom   string
+		ligne string
+	}{
+		{nom: "guillemet non fermé", ligne: `GET "rapport final.pdf`},
+		{nom: "guillemet fermant échappé", ligne: `GET "rapport\"`},
+		{nom: "guillemet au milieu d'un mot", ligne: `GET rap"port.pdf`},
+		{nom: "guillemet en fin de mot", ligne: `GET rapport"`},
+		{nom: "caractères après un guillemet fermant", ligne: `GET "rapport"final`},
+		{nom: "guillemet après un guillemet fermant", ligne: `GET "a""b"`},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			args, err := SplitArgs(c.ligne)
+			if !errors.Is(err, ErrSyntax) {
+				t.Fatalf("SplitArgs(%q) = %q, %v ; attendu ErrSyntax", c.ligne, args, err)
+			}
+		})
+	}
+}
+
+func TestParseRequestRejets(t *testing.T) {
+	for _, ligne := range []string{"", "   ", "\r\n", "GET \xff\xfe", `GET "a`} {
+		if req, err := ParseRequest(ligne); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ParseRequest(%q) = %#v, %v ; attendu ErrSyntax", ligne, req, err)
+		}
+	}
+}
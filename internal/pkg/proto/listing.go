@@ -0,0 +1,185 @@
+package proto
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Les listes (LIST, TREE, MLSD) sont envoyées en flux, un élément par ligne, pour ne jamais construire
+// une liste complète en mémoire :
+//
+//	150 <texte>          le serveur est prêt, le client répond "OK"
+//	151 <élément>        un élément (FormatEntry, FormatDetail ou objet JSON), répété
+//	111 <texte>          (optionnel) la liste est tronquée, limite d'éléments atteinte
+//	226 <nombre>         marqueur de fin, nombre d'éléments envoyés
+//
+// Dans l'esprit du format "FileCnt" de consignes.md, mais le nombre arrive à la fin : le serveur lit
+// le dossier au fil de l'envoi et ne le connaît pas à l'avance.
+
+// Entry : élément d'une liste envoyée par LIST ou TREE.
+// Pour TREE, Name est le chemin relatif au dossier listé, séparé par '/' ;
+// un dossier se termine par '/' ("docs/", "docs/salut.txt").
+type Entry struct {
+	Name string
+	Size int64
+}
+
+// FormatEntry formate un élément de LIST ou TREE : "<nom> <taille>".
+// Le nom est placé entre guillemets si nécessaire (voir Quote) : la ligne reste lisible quel que soit le nom.
+func FormatEntry(e Entry) string {
+	return Quote(e.Name) + " " + strconv.FormatInt(e.Size, 10)
+}
+
+// ParseEntry lit un élément formaté par FormatEntry.
+func ParseEntry(text string) (Entry, error) {
+	champs, err := SplitArgs(text)
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(champs) != 2 {
+		return Entry{}, fmt.Errorf("%w : élément de liste invalide %q", ErrSyntax, text)
+	}
+	taille, err := strconv.ParseInt(champs[1], 10, 64)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%w : taille invalide %q pour %q", ErrSyntax, champs[1], champs[0])
+	}
+	return Entry{Name: champs[0], Size: taille}, nil
+}
+
+// ParseCount lit le nombre d'éléments annoncé par le marqueur de fin "226 <nombre>".
+func ParseCount(text string) (int, error) {
+	nombre, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || nombre < 0 {
+		return 0, fmt.Errorf("%w : nombre d'éléments invalide %q", ErrSyntax, text)
+	}
+	return nombre, nil
+}
+
+// OptionJSON : dernier argument de LIST et TREE demandant une liste JSON (fonctionnalité "json").
+// Chaque élément du flux est alors un objet JSON FileInfo sur une ligne (format JSON Lines).
+const OptionJSON = "-json"
+
+// CutOption retire option de args si c'est le dernier argument, et indique si elle était présente.
+func CutOption(args []string, option string) ([]string, bool) {
+	if len(args) > 0 && args[len(args)-1] == option {
+		return args[:len(args)-1], true
+	}
+	return args, false
+}
+
+// Types d'élément d'une liste JSON.
+const (
+	TypeFile    = "file"
+	TypeDir     = "dir"
+	TypeSymlink = "symlink"
+	TypeOther   = "other"
+)
+
+// FileInfo : élément d'une liste JSON (LIST -json, TREE -json) ou détaillée (MLSD).
+// Owner est vide si le système ne le fournit pas.
+//
+// TREE -json envoie l'arbre à plat, un objet par ligne, chaque dossier avant son contenu : Name est le chemin
+// relatif au dossier listé, séparé par '/' et sans '/' final ("docs", "docs/salut.txt"), et Depth la profondeur
+// de l'élément (1 pour le contenu du dossier listé), qui suffit à reconstruire l'arbre sans découper les chemins.
+// Ce format remplace le tableau de FileInfo imbriqués (champ "children") des premières versions de TREE -json.
+// Depth est absent des listes LIST et MLSD.
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Type    string    `json:"type"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+	Owner   string    `json:"owner,omitempty"`
+	Hidden  bool      `json:"hidden"`
+	Depth   int       `json:"depth,omitempty"`
+}
+
+// NewFileInfo décrit info, obtenu sans suivre les liens symboliques ; le nom est normalisé en NFC.
+func NewFileInfo(info fs.FileInfo) FileInfo {
+	var genre = TypeOther
+	switch {
+	case info.Mode().IsRegular():
+		genre = TypeFile
+	case info.IsDir():
+		genre = TypeDir
+	case info.Mode()&fs.ModeSymlink != 0:
+		genre = TypeSymlink
+	}
+	return FileInfo{
+		Name:    NormalizeName(info.Name()),
+		Size:    info.Size(),
+		Type:    genre,
+		Mode:    info.Mode().String(),
+		ModTime: info.ModTime(),
+		Hidden:  strings.HasPrefix(info.Name(), "."),
+	}
+}
+
+// formatModify : format de la date de modification d'un élément MLSD (UTC, comme le fait "modify" de FTP).
+const formatModify = "20060102150405"
+
+// FormatFacts formate un élément de la liste MLSD, dans l'esprit de la RFC 3659 :
+// "type=file;size=7;modify=20251017031910;perm=-rw-r--r--;owner=alice;hidden=false; nom".
+// Le nom est tout ce qui suit "; " : il peut contenir n'importe quel caractère.
+func FormatFacts(f FileInfo) string {
+	return "type=" + f.Type +
+		";size=" + strconv.FormatInt(f.Size, 10) +
+		";modify=" + f.ModTime.UTC().Format(formatModify) +
+		";perm=" + f.Mode +
+		";owner=" + strings.NewReplacer(";", "_", " ", "_").Replace(f.Owner) +
+		";hidden=" + strconv.FormatBool(f.Hidden) +
+		"; " + f.Name
+}
+
+// ParseFacts lit un élément formaté par FormatFacts. Les faits inconnus sont ignorés.
+func ParseFacts(record string) (FileInfo, error) {
+	faits, nom, ok := strings.Cut(record, "; ")
+	if !ok || nom == "" {
+		return FileInfo{}, fmt.Errorf("%w : élément MLSD sans nom %q", ErrSyntax, record)
+	}
+
+	var f = FileInfo{Name: nom}
+	for _, fait := range strings.Split(faits, ";") {
+		cle, valeur, _ := strings.Cut(fait, "=")
+		var err error
+		switch cle {
+		case "type":
+			f.Type = valeur
+		case "size":
+			f.Size, err = strconv.ParseInt(valeur, 10, 64)
+		case "modify":
+			f.ModTime, err = time.Parse(formatModify, valeur)
+		case "perm":
+			f.Mode = valeur
+		case "owner":
+			f.Owner = valeur
+		case "hidden":
+			f.Hidden, err = strconv.ParseBool(valeur)
+		}
+		if err != nil {
+			return FileInfo{}, fmt.Errorf("%w : fait MLSD invalide %q", ErrSyntax, fait)
+		}
+	}
+	return f, nil
+}
+
+// FormatDetail formate un élément du flux MLSD : les faits (voir FormatFacts), entre guillemets si nécessaire
+// pour qu'un nom contenant un retour à la ligne tienne sur une ligne.
+func FormatDetail(f FileInfo) string {
+	return Quote(FormatFacts(f))
+}
+
+// ParseDetail lit un élément formaté par FormatDetail.
+func ParseDetail(text string) (FileInfo, error) {
+	champs, err := SplitArgs(text)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if len(champs) != 1 {
+		return FileInfo{}, fmt.Errorf("%w : élément MLSD invalide %q", ErrSyntax, text)
+	}
+	return ParseFacts(champs[0])
+}
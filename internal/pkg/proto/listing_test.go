@@ -0,0 +1,51 @@
+package proto
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFormatParseEntry(t *testing.T) {
+	for _, nom := range []string{"salut.txt", "docs/", "rapport final.pdf", `dit "oui".txt`, "a\nb", "-json", "docs/sous dossier/"} {
+		var e = Entry{Name: nom, Size: 1234}
+		lu, err := ParseEntry(FormatEntry(e))
+		if err != nil || lu != e {
+			t.Errorf("ParseEntry(FormatEntry(%#v)) = %#v, %v", e, lu, err)
+		}
+	}
+}
+
+func TestParseEntryRejets(t *testing.T) {
+	for _, texte := range []string{"", "salut.txt", "rapport final.pdf 12", "salut.txt douze", `"salut.txt 12`, "a b c"} {
+		if e, err := ParseEntry(texte); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ParseEntry(%q) = %#v, %v ; attendu ErrSyntax", texte, e, err)
+		}
+	}
+}
+
+func TestFormatParseDetail(t *testing.T) {
+	var date = time.Date(2025, 10, 17, 3, 19, 10, 0, time.UTC)
+	for _, nom := range []string{"salut.txt", "rapport final.pdf", "a; b.txt", "type=dir;x", `dit "oui"`, "a\nb"} {
+		var f = FileInfo{Name: nom, Size: 7, Type: TypeFile, Mode: "-rw-r--r--", ModTime: date, Owner: "alice", Hidden: true}
+		lu, err := ParseDetail(FormatDetail(f))
+		if err != nil || lu != f {
+			t.Errorf("ParseDetail(FormatDetail(%q)) = %#v, %v", nom, lu, err)
+		}
+	}
+}
+
+func TestParseFacts(t *testing.T) {
+	// Faits inconnus ignorés, propriétaire contenant ';' ou ' ' remplacé par '_'
+	var f = FileInfo{Name: "n", Type: TypeDir, Owner: "dom;ain user", ModTime: time.Unix(0, 0).UTC()}
+	lu, err := ParseFacts("unique=42;" + FormatFacts(f))
+	if err != nil || lu.Owner != "dom_ain_user" || lu.Type != TypeDir || lu.Name != "n" {
+		t.Fatalf("ParseFacts = %#v, %v", lu, err)
+	}
+
+	for _, texte := range []string{"", "type=file;size=1", "type=file; ", "size=douze; n", "hidden=peut-être; n", "modify=hier; n"} {
+		if f, err := ParseFacts(texte); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ParseFacts(%q) = %#v, %v ; attendu ErrSyntax", texte, f, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package proto
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Fonctionnalités optionnelles du protocole, annoncées par chaque côté à l'ouverture de la session.
+const (
+	FeatureBinary   = "binary"   // transferts GET/PUT en flux binaire annoncé par sa taille
+	FeatureResume   = "resume"   // reprise d'un GET à partir d'un offset
+	FeatureChecksum = "checksum" // empreinte SHA-256 après un GET, commande SUM
+	FeatureCompress = "compress" // compression des transferts (réservée, pas encore implémentée)
+	FeatureJSON     = "json"     // listes LIST/TREE au format JSON (option -json)
+	FeatureMLSD     = "mlsd"     // liste détaillée MLSD (type, permissions, date, propriétaire)
+	FeatureNoop     = "noop"     // commande NOOP, envoyée par un client interactif pour maintenir sa session
+)
+
+// ParamIdle : paramètre "idle=<secondes>" ajouté par le serveur aux fonctionnalités de son accueil : délai d'inactivité
+// après lequel il ferme la session (absent : pas de limite). Comme toute fonctionnalité inconnue, il est ignoré
+// à la négociation par un client qui ne le connaît pas.
+const ParamIdle = "idle"
+
+// ProtocolVersion : version du protocole implémentée par ce paquet.
+// MinProtocolVersion : plus ancienne version encore acceptée de l'autre côté.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// SupportedFeatures : fonctionnalités implémentées par ce paquet.
+var SupportedFeatures = []string{FeatureBinary, FeatureResume, FeatureChecksum, FeatureJSON, FeatureMLSD, FeatureNoop}
+
+// RequiredFeatures : fonctionnalités sans lesquelles aucun transfert n'est possible.
+var RequiredFeatures = []string{FeatureBinary}
+
+// ErrVersion : aucune version du protocole commune aux deux côtés.
+var ErrVersion = errors.New("version du protocole incompatible")
+
+// ErrFeature : une fonctionnalité obligatoire n'est pas supportée par l'autre côté.
+var ErrFeature = errors.New("fonctionnalité obligatoire non supportée")
+
+// Capabilities : version et fonctionnalités annoncées (ou négociées) pour une session.
+// Sur le fil : "<version> <f1,f2,...>", "-" pour une liste vide.
+type Capabilities struct {
+	Version  int
+	Features []string
+}
+
+// Local retourne les capacités de cette implémentation.
+func Local() Capabilities {
+	return Capabilities{Version: ProtocolVersion, Features: slices.Clone(SupportedFeatures)}
+}
+
+// Has indique si la fonctionnalité f fait partie des capacités.
+func (c Capabilities) Has(f string) bool {
+	return slices.Contains(c.Features, f)
+}
+
+// WithParam retourne une copie des capacités avec le paramètre "nom=valeur" ajouté aux fonctionnalités.
+func (c Capabilities) WithParam(nom, valeur string) Capabilities {
+	c.Features = append(slices.Clone(c.Features), nom+"="+valeur)
+	return c
+}
+
+// Param retourne la valeur du paramètre nom annoncé dans les fonctionnalités ("nom=valeur"), false s'il est absent.
+func (c Capabilities) Param(nom string) (string, bool) {
+	for _, f := range c.Features {
+		if valeur, ok := strings.CutPrefix(f, nom+"="); ok {
+			return valeur, true
+		}
+	}
+	return "", false
+}
+
+// String formate les capacités comme sur le fil : "<version> <f1,f2,...>".
+func (c Capabilities) String() string {
+	var liste = "-"
+	if len(c.Features) > 0 {
+		liste = strings.Join(c.Features, ",")
+	}
+	return strconv.Itoa(c.Version) + " " + liste
+}
+
+// ParseCapabilities lit "<version> <f1,f2,...>" en tête de s et retourne le reste de la ligne.
+func ParseCapabilities(s string) (Capabilities, string, error) {
+	champs := strings.SplitN(strings.TrimSpace(s), " ", 3)
+	if len(champs) < 2 {
+		return Capabilities{}, "", fmt.Errorf("%w : version et fonctionnalités absentes", ErrVersion)
+	}
+	version, err := strconv.Atoi(champs[0])
+	if err != nil || version < 1 {
+		return Capabilities{}, "", fmt.Errorf("%w : version invalide %q", ErrVersion, champs[0])
+	}
+
+	var c = Capabilities{Version: version}
+	if champs[1] != "-" {
+		c.Features = strings.Split(champs[1], ",")
+	}
+	var reste string
+	if len(champs) == 3 {
+		reste = champs[2]
+	}
+	return c, reste, nil
+}
+
+// Negotiate calcule les capacités communes : la plus petite des deux versions et les fonctionnalités
+// supportées des deux côtés. Les deux côtés obtiennent le même résultat à partir des mêmes annonces.
+// Erreur si la version commune est trop ancienne ou si une fonctionnalité obligatoire manque.
+func Negotiate(local Capabilities, remote Capabilities) (Capabilities, error) {
+	var c = Capabilities{Version: min(local.Version, remote.Version)}
+	if c.Version < MinProtocolVersion {
+		return Capabilities{}, fmt.Errorf("%w : version %d, minimum %d", ErrVersion, c.Version, MinProtocolVersion)
+	}
+
+	for _, f := range local.Features {
+		if remote.Has(f) {
+			c.Features = append(c.Features, f)
+		}
+	}
+	for _, f := range RequiredFeatures {
+		if !c.Has(f) {
+			return Capabilities{}, fmt.Errorf("%w : %s", ErrFeature, f)
+		}
+	}
+	return c, nil
+}
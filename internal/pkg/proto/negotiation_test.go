@@ -0,0 +1,132 @@
+package proto
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	var cas = []struct {
+		nom     string
+		texte   string
+		attendu Capabilities
+		reste   string
+		erreur  bool
+	}{
+		{nom: "accueil", texte: "1 binary,resume Docs", attendu: Capabilities{Version: 1, Features: []string{"binary", "resume"}}, reste: "Docs"},
+		{nom: "position avec espaces", texte: "2 binary Docs/mes documents", attendu: Capabilities{Version: 2, Features: []string{"binary"}}, reste: "Docs/mes documents"},
+		{nom: "aucune fonctionnalité", texte: "1 -", attendu: Capabilities{Version: 1}},
+		{nom: "paramètre annoncé", texte: "1 binary,idle=300 Docs", attendu: Capabilities{Version: 1, Features: []string{"binary", "idle=300"}}, reste: "Docs"},
+		{nom: "vide", texte: "", erreur: true},
+		{nom: "sans fonctionnalités", texte: "1", erreur: true},
+		{nom: "version non numérique", texte: "v1 binary", erreur: true},
+		{nom: "version nulle", texte: "0 binary", erreur: true},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			caps, reste, err := ParseCapabilities(c.texte)
+			if c.erreur {
+				if !errors.Is(err, ErrVersion) {
+					t.Fatalf("ParseCapabilities(%q) = %v, %q, %v ; attendu ErrVersion", c.texte, caps, reste, err)
+				}
+				return
+			}
+			if err != nil || caps.Version != c.attendu.Version || !slices.Equal(caps.Features, c.attendu.Features) || reste != c.reste {
+				t.Fatalf("ParseCapabilities(%q) = %v, %q, %v ; attendu %v, %q", c.texte, caps, reste, err, c.attendu, c.reste)
+			}
+		})
+	}
+
+	// Les capacités formatées sont relues à l'identique
+	var local = Local()
+	if lu, _, err := ParseCapabilities(local.String()); err != nil || lu.Version != local.Version || !slices.Equal(lu.Features, local.Features) {
+		t.Fatalf("ParseCapabilities(%q) = %v, %v", local.String(), lu, err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	var cas = []struct {
+		nom     string
+		local   Capabilities
+		distant Capabilities
+		attendu Capabilities
+		erreur  error
+	}{
+		{
+			nom:     "mêmes capacités",
+			local:   Capabilities{Version: 1, Features: []string{FeatureBinary, FeatureJSON}},
+			distant: Capabilities{Version: 1, Features: []string{FeatureBinary, FeatureJSON}},
+			attendu: Capabilities{Version: 1, Features: []string{FeatureBinary, FeatureJSON}},
+		},
+		{
+			nom:     "repli sur la version la plus ancienne",
+			local:   Capabilities{Version: 3, Features: []string{FeatureBinary}},
+			distant: Capabilities{Version: 1, Features: []string{FeatureBinary}},
+			attendu: Capabilities{Version: 1, Features: []string{FeatureBinary}},
+		},
+		{
+			nom:     "repli sur les fonctionnalités communes, dans l'ordre local",
+			local:   Capabilities{Version: 1, Features: []string{FeatureBinary, FeatureResume, FeatureChecksum}},
+			distant: Capabilities{Version: 1, Features: []string{FeatureChecksum, FeatureBinary, FeatureCompress}},
+			attendu: Capabilities{Version: 1, Features: []string{FeatureBinary, FeatureChecksum}},
+		},
+		{
+			nom:     "paramètre du serveur ignoré",
+			local:   Capabilities{Version: 1, Features: []string{FeatureBinary, FeatureNoop}},
+			distant: Local().WithParam(ParamIdle, "300"),
+			attendu: Capabilities{Version: 1, Features: []string{FeatureBinary, FeatureNoop}},
+		},
+		{
+			nom:     "version trop ancienne",
+			local:   Capabilities{Version: 1, Features: []string{FeatureBinary}},
+			distant: Capabilities{Version: MinProtocolVersion - 1, Features: []string{FeatureBinary}},
+			erreur:  ErrVersion,
+		},
+		{
+			nom:     "fonctionnalité obligatoire absente de l'autre côté",
+			local:   Capabilities{Version: 1, Features: []string{FeatureBinary, FeatureJSON}},
+			distant: Capabilities{Version: 1, Features: []string{FeatureJSON}},
+			erreur:  ErrFeature,
+		},
+		{
+			nom:     "fonctionnalité obligatoire absente localement",
+			local:   Capabilities{Version: 1},
+			distant: Capabilities{Version: 1, Features: []string{FeatureBinary}},
+			erreur:  ErrFeature,
+		},
+	}
+	for _, c := range cas {
+		t.Run(c.nom, func(t *testing.T) {
+			caps, err := Negotiate(c.local, c.distant)
+			if c.erreur != nil {
+				if !errors.Is(err, c.erreur) {
+					t.Fatalf("Negotiate = %v, %v ; attendu %v", caps, err, c.erreur)
+				}
+				return
+			}
+			if err != nil || caps.Version != c.attendu.Version || !slices.Equal(caps.Features, c.attendu.Features) {
+				t.Fatalf("Negotiate = %v, %v ; attendu %v", caps, err, c.attendu)
+			}
+
+			// Les deux côtés obtiennent la même version et les mêmes fonctionnalités (à l'ordre près)
+			inverse, err := Negotiate(c.distant, c.local)
+			if err != nil || inverse.Version != caps.Version || len(inverse.Features) != len(caps.Features) {
+				t.Fatalf("Negotiate inversé = %v, %v ; attendu %v", inverse, err, caps)
+			}
+		})
+	}
+}
+
+func TestParam(t *testing.T) {
+	var caps = Local().WithParam(ParamIdle, "300")
+	if valeur, ok := caps.Param(ParamIdle); !ok || valeur != "300" {
+		t.Fatalf("Param(idle) = %q, %v ; attendu \"300\"", valeur, ok)
+	}
+	if slices.Contains(Local().Features, "idle=300") {
+		t.Fatal("WithParam a modifié les fonctionnalités de Local()")
+	}
+	if valeur, ok := Local().Param(ParamIdle); ok {
+		t.Fatalf("Param(idle) sans annonce = %q, true", valeur)
+	}
+}
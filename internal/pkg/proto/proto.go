@@ -2,14 +2,20 @@ package proto
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"os"
 	"strings"
 	"time"
 )
 
-// MessageTimeout=Timeout par défaut
+// MessageTimeout : délai maximal de chaque lecture ou écriture pendant un échange (message attendu en réponse,
+// bloc de données d'un transfert). Un transfert peut durer plus longtemps tant qu'il progresse.
+// L'attente d'une commande entre deux échanges a son propre délai (voir Conn.WaitRequest).
 var MessageTimeout = 20 * time.Second
 
 // --- GESTION DE L'HISTORIQUE DES MESSAGES ---
@@ -80,8 +86,17 @@ func Send_message(conn net.Conn, out *bufio.Writer, message string) error {
 }
 
 func Receive_message(conn net.Conn, in *bufio.Reader) (string, error) {
+	return receiveMessageWithin(conn, in, MessageTimeout)
+}
+
+// receiveMessageWithin lit un message en au plus timeout (0 : pas de limite).
+func receiveMessageWithin(conn net.Conn, in *bufio.Reader, timeout time.Duration) (string, error) {
 	// Définir une deadline pour l'opération de lecture
-	if err := conn.SetReadDeadline(time.Now().Add(MessageTimeout)); err != nil {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
 		return "", fmt.Errorf("erreur définition deadline lecture: %w", err)
 	}
 
@@ -103,3 +118,92 @@ func Receive_message(conn net.Conn, in *bufio.Reader) (string, error) {
 
 	return message, nil
 }
+
+// --- GESTION DES TRANSFERTS DE DONNÉES BINAIRES ---
+
+// deadlineWriter repousse la deadline d'écriture avant chaque bloc écrit.
+// Le timeout porte ainsi sur la progression du transfert et non sur sa durée totale.
+type deadlineWriter struct {
+	conn net.Conn
+	out  io.Writer
+}
+
+func (d deadlineWriter) Write(b []byte) (int, error) {
+	if err := d.conn.SetWriteDeadline(time.Now().Add(MessageTimeout)); err != nil {
+		return 0, fmt.Errorf("erreur définition deadline écriture: %w", err)
+	}
+	return d.out.Write(b)
+}
+
+// deadlineReader repousse la deadline de lecture avant chaque bloc lu.
+type deadlineReader struct {
+	conn net.Conn
+	in   io.Reader
+}
+
+func (d deadlineReader) Read(b []byte) (int, error) {
+	if err := d.conn.SetReadDeadline(time.Now().Add(MessageTimeout)); err != nil {
+		return 0, fmt.Errorf("erreur définition deadline lecture: %w", err)
+	}
+	return d.in.Read(b)
+}
+
+// Send_data envoie exactement size octets lus depuis src, sous forme de flux binaire brut.
+// Le flux n'est pas terminé par un retour à la ligne : le destinataire connaît la taille à lire.
+func Send_data(conn net.Conn, out *bufio.Writer, src io.Reader, size int64) error {
+	defer func(conn net.Conn, t time.Time) {
+		err := conn.SetWriteDeadline(t)
+		if err != nil {
+			log.Println("Erreur SetWriteDeadline defer:", err)
+		}
+	}(conn, time.Time{})
+
+	n, err := io.CopyN(deadlineWriter{conn: conn, out: out}, src, size)
+	if err != nil {
+		return fmt.Errorf("erreur envoi données (%d/%d octets): %w", n, size, err)
+	}
+
+	if err := conn.SetWriteDeadline(time.Now().Add(MessageTimeout)); err != nil {
+		return fmt.Errorf("erreur définition deadline écriture: %w", err)
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("erreur flush: %w", err)
+	}
+
+	LogMessage("sent", fmt.Sprintf("<%d octets de données>", n))
+	return nil
+}
+
+// Receive_data lit exactement size octets sur la connexion et les écrit dans dst.
+// La lecture passe par le même bufio.Reader que les messages pour ne perdre aucun octet déjà bufferisé.
+func Receive_data(conn net.Conn, in *bufio.Reader, dst io.Writer, size int64) error {
+	defer func(conn net.Conn, t time.Time) {
+		err := conn.SetReadDeadline(t)
+		if err != nil {
+			log.Println("Erreur SetReadDeadline defer:", err)
+		}
+	}(conn, time.Time{})
+
+	n, err := io.CopyN(dst, deadlineReader{conn: conn, in: in}, size)
+	if err != nil {
+		return fmt.Errorf("erreur réception données (%d/%d octets): %w", n, size, err)
+	}
+
+	LogMessage("received", fmt.Sprintf("<%d octets de données>", n))
+	return nil
+}
+
+// ChecksumFile calcule l'empreinte SHA-256 (en hexadécimal) du contenu du fichier path.
+func ChecksumFile(path string) (string, error) {
+	fichier, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fichier.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, fichier); err != nil {
+		return "", fmt.Errorf("erreur lecture %s: %w", path, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}